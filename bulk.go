@@ -0,0 +1,181 @@
+package gotomic
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// BulkIterator produces the entries for BulkLoad, one per call, and
+// returns ok == false when there are no more.
+type BulkIterator func() (k Key, v unsafe.Pointer, ok bool)
+
+type bulkPair struct {
+	key   Key
+	value unsafe.Pointer
+}
+
+type bulkElement struct {
+	element *element
+	seq     int
+}
+
+// NewHashFrom returns a Hash containing the contents of m, built with
+// BulkLoad.
+func NewHashFrom(m map[Key]unsafe.Pointer) *Hash {
+	rval := NewHash()
+	pairs := make([]bulkPair, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, bulkPair{k, v})
+	}
+	rval.bulkLoad(pairs, 1)
+	return rval
+}
+
+// BulkLoad fills the Hash with the entries produced by next, using up
+// to workers goroutines. When a key is produced more than once, the
+// last value wins, like with Put.
+//
+// Instead of inserting the entries one at a time, BulkLoad sizes the
+// bucket table for all of them, sorts them in split order, and links
+// them and all the sentinel elements directly. The split order is
+// divided into one range per worker, and each range is sorted and
+// linked in its own goroutine.
+//
+// This is only safe while no other goroutine is using the Hash. If the
+// Hash already contains entries, BulkLoad just Puts the new ones.
+func (self *Hash) BulkLoad(next BulkIterator, workers int) {
+	var pairs []bulkPair
+	for {
+		k, v, ok := next()
+		if !ok {
+			break
+		}
+		pairs = append(pairs, bulkPair{k, v})
+	}
+	self.bulkLoad(pairs, workers)
+}
+
+func (self *Hash) bulkLoad(pairs []bulkPair, workers int) {
+	if self.Size() > 0 {
+		for _, pair := range pairs {
+			self.Put(pair.key, pair.value)
+		}
+		return
+	}
+	for exp := self.exponentFor(len(pairs)); self.exponent < exp; {
+		self.grow()
+	}
+	exp := self.exponent
+	// Use a power of two of ranges, at most one per bucket.
+	var rangeExp uint32
+	for rangeExp < exp && 1<<(rangeExp+1) <= workers {
+		rangeExp++
+	}
+	ranges := 1 << rangeExp
+	// Hash the keys and sort the elements into ranges, in one chunk of pairs per range.
+	local := make([][][]bulkElement, ranges)
+	chunk := (len(pairs) + ranges - 1) / ranges
+	var wg sync.WaitGroup
+	for w := 0; w < ranges; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			local[w] = make([][]bulkElement, ranges)
+			for seq := w * chunk; seq < (w+1)*chunk && seq < len(pairs); seq++ {
+				e := newRealEntryWithHashCode(pairs[seq].key, pairs[seq].value, self.hashCode(pairs[seq].key))
+				r := e.hashKey >> (max_exponent - rangeExp)
+				n := self.newElement()
+				n.entry = *e
+				local[w][r] = append(local[w][r], bulkElement{n, seq})
+			}
+		}(w)
+	}
+	wg.Wait()
+	// Sort and link each range.
+	firsts := make([]*element, ranges)
+	lasts := make([]*element, ranges)
+	sizes := make([]int64, ranges)
+	for r := 0; r < ranges; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			var elements []bulkElement
+			for w := 0; w < ranges; w++ {
+				elements = append(elements, local[w][r]...)
+			}
+			slices.SortFunc(elements, func(a, b bulkElement) int {
+				if c := cmp.Compare(a.element.entry.hashKey, b.element.entry.hashKey); c != 0 {
+					return c
+				}
+				return cmp.Compare(a.seq, b.seq)
+			})
+			firsts[r], lasts[r], sizes[r] = self.linkRange(uint64(r)<<(exp-rangeExp), uint64(r+1)<<(exp-rangeExp), elements)
+		}(r)
+	}
+	wg.Wait()
+	for r := 0; r < ranges-1; r++ {
+		lasts[r].Pointer = unsafe.Pointer(firsts[r+1])
+	}
+	// The list now has exactly one sentinel per bucket.
+	atomic.StoreInt64(&self.sentinels, 1<<exp)
+	var size int64
+	for _, s := range sizes {
+		size += s
+	}
+	self.addSize(int(size))
+}
+
+// linkRange links the sentinel elements for the split order positions
+// from start to end at the current exponent, with the sorted elements
+// that belong after them. Of elements with the same key, only the last
+// one is linked. It returns the first and last element linked, and the
+// number of real ones.
+func (self *Hash) linkRange(start, end uint64, elements []bulkElement) (first, last *element, size int64) {
+	exp := self.exponent
+	link := func(e *element) {
+		if last == nil {
+			first = e
+		} else {
+			last.Pointer = unsafe.Pointer(e)
+		}
+		last = e
+	}
+	i := 0
+	for position := start; position < end; position++ {
+		index := reverse64(position << (max_exponent - exp))
+		subBuckets, subIndex := self.getSubBuckets(index)
+		bucket := (*element)(subBuckets[subIndex])
+		if bucket == nil {
+			bucket = &element{entry: *newMockEntry(index)}
+			subBuckets[subIndex] = unsafe.Pointer(bucket)
+		}
+		link(bucket)
+		for ; i < len(elements) && elements[i].element.entry.hashKey>>(max_exponent-exp) == position; i++ {
+			e := elements[i].element
+			if self.bulkDuplicate(elements[i+1:], e) {
+				continue
+			}
+			link(e)
+			size++
+		}
+	}
+	last.Pointer = nil
+	return
+}
+
+// bulkDuplicate returns whether e has the same key as any of the
+// following elements with the same hash key.
+func (self *Hash) bulkDuplicate(following []bulkElement, e *element) bool {
+	for _, f := range following {
+		if f.element.entry.hashKey != e.entry.hashKey {
+			return false
+		}
+		if f.element.entry.key == e.entry.key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package gotomic
+
+import (
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+func assertSplitOrdered(t *testing.T, h *Hash) {
+	var previous uint64
+	h.getBucketByIndex(0).each(func(e entry) bool {
+		if e.hashKey < previous {
+			t.Error(h, "should be in split order, but", &e, "came after hash key", previous)
+		}
+		previous = e.hashKey
+		return false
+	})
+}
+
+func TestNewHashFrom(t *testing.T) {
+	n := 10000
+	values := make([]int, n)
+	m := make(map[Key]unsafe.Pointer)
+	for i := 0; i < n; i++ {
+		values[i] = i
+		m[MakeKey(uint64(i))] = unsafe.Pointer(&values[i])
+	}
+	h := NewHashFrom(m)
+	assertSplitOrdered(t, h)
+	if h.Size() != n {
+		t.Error(h, "should have size", n, "but had", h.Size())
+	}
+	for i := 0; i < n; i++ {
+		if v, ok := h.Get(MakeKey(uint64(i))); !ok || *(*int)(v) != i {
+			t.Error(h, "should contain", i)
+		}
+	}
+	fmt.Println("...Done TestNewHashFrom")
+}
+
+func TestHashBulkLoad(t *testing.T) {
+	n := 10000
+	values := make([]int, n)
+	for _, workers := range []int{1, 3, 4, 16} {
+		h := NewHash()
+		i := 0
+		h.BulkLoad(func() (Key, unsafe.Pointer, bool) {
+			if i == 2*n {
+				return Key{}, nil, false
+			}
+			// Every key twice, the second time with the right value.
+			k := (i / 2) % n
+			if i%2 == 0 {
+				k = (k + 1) % n
+			}
+			values[k] = k
+			i++
+			return MakeKey(uint64(k)), unsafe.Pointer(&values[k]), true
+		}, workers)
+		assertSplitOrdered(t, h)
+		if h.Size() != n {
+			t.Error(h, "should have size", n, "but had", h.Size())
+		}
+		for j := 0; j < n; j++ {
+			if v, ok := h.Get(MakeKey(uint64(j))); !ok || *(*int)(v) != j {
+				t.Error(h, "should contain", j)
+			}
+		}
+		for j := n; j < 2*n; j++ {
+			h.Put(MakeKey(uint64(j)), nil)
+		}
+		for j := 0; j < 2*n; j++ {
+			if _, ok := h.Delete(MakeKey(uint64(j))); !ok {
+				t.Error(h, "should contain", j)
+			}
+		}
+		if h.Size() != 0 {
+			t.Error(h, "should be empty but had size", h.Size())
+		}
+	}
+	h := NewHash()
+	h.Put(MakeKey(1), nil)
+	h.BulkLoad(func() (Key, unsafe.Pointer, bool) {
+		return Key{}, nil, false
+	}, 4)
+	if h.Size() != 1 {
+		t.Error(h, "should keep its entries, but had size", h.Size())
+	}
+	fmt.Println("...Done TestHashBulkLoad")
+}
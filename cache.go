@@ -0,0 +1,184 @@
+package gotomic
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// EvictFunc is called with the key and value of each entry a Cache
+// evicts.
+type EvictFunc func(k Key, v unsafe.Pointer)
+
+// CacheOptions configures a Cache created by NewCache.
+type CacheOptions struct {
+	// MaxEntries is the number of entries above which the Cache evicts. 0 means no limit.
+	MaxEntries int
+	// MaxWeight is the total weight above which the Cache evicts. 0 means no limit.
+	MaxWeight int64
+	// Weigher returns the weight of an entry, and must always return the
+	// same weight for the same key and value. Defaults to 1 per entry.
+	Weigher func(k Key, v unsafe.Pointer) int64
+	// OnEvict, if not nil, is called for each evicted entry.
+	OnEvict EvictFunc
+}
+
+// Cache is a Hash with a bounded number of entries, or a bounded total
+// weight, that evicts with the CLOCK algorithm.
+//
+// Each entry has a reference bit, next to it in its element, that Get
+// sets. To evict, the clock hand moves along the split-ordered list,
+// clearing the reference bits it finds set, until it finds an entry
+// whose bit is clear. The hand is just a position in the list, so
+// evicting takes no locks, and Get costs only the reference bit over
+// Hash.GetHC.
+//
+// A Cache can briefly exceed its limits while Puts racing each other
+// are evicting.
+type Cache struct {
+	hash    *Hash
+	options CacheOptions
+	weight  int64
+	// hand is the hash key the next eviction starts at.
+	hand uint64
+}
+
+// cacheElement is an element of a Cache, with the CLOCK reference bit
+// of its entry.
+type cacheElement struct {
+	element    element
+	referenced uint32
+}
+
+func newCacheElement() *element {
+	return &(&cacheElement{}).element
+}
+
+// referenced returns the reference bit of n, a real element of a Cache.
+func referenced(n *element) *uint32 {
+	return &(*cacheElement)(unsafe.Pointer(n)).referenced
+}
+
+func NewCache(options CacheOptions) *Cache {
+	rval := &Cache{hash: NewHash(), options: options}
+	rval.hash.elements = newCacheElement
+	return rval
+}
+
+func (self *Cache) weigh(k Key, v unsafe.Pointer) int64 {
+	if self.options.Weigher == nil {
+		return 1
+	}
+	return self.options.Weigher(k, v)
+}
+
+func (self *Cache) Size() int {
+	return self.hash.Size()
+}
+
+// Weight returns the total weight of the entries in the Cache.
+func (self *Cache) Weight() int64 {
+	return atomic.LoadInt64(&self.weight)
+}
+
+// HashCode returns the hash code the Cache uses for k, for use with GetHC.
+func (self *Cache) HashCode(k Key) uint32 {
+	return self.hash.HashCode(k)
+}
+
+// Each will run i on each key and value, and return true if the iteration was interrupted.
+// It does not count as a use of the entries.
+func (self *Cache) Each(i HashIterator) bool {
+	return self.hash.Each(i)
+}
+
+// GetHC returns the value at k and whether it was present, using hashCode and ld to avoid allocating.
+func (self *Cache) GetHC(hashCode uint32, k Key, ld *LocalData) (unsafe.Pointer, bool) {
+	ld.te.Set(uint64(hashCode), k)
+	n, rval, ok := self.hash.getLocalEntry(ld)
+	// Only write the bit if needed, to keep hot entries from bouncing between caches.
+	if ok && atomic.LoadUint32(referenced(n)) == 0 {
+		atomic.StoreUint32(referenced(n), 1)
+	}
+	return rval, ok
+}
+
+// Get returns the value at k and whether it was present in the Cache.
+func (self *Cache) Get(k Key) (unsafe.Pointer, bool) {
+	return self.GetHC(self.HashCode(k), k, InitLocalData())
+}
+
+// Put k and v in the Cache, evicting other entries if it becomes too big, and return the overwritten value and whether any value was overwritten.
+func (self *Cache) Put(k Key, v unsafe.Pointer) (rval unsafe.Pointer, ok bool) {
+	delta := self.weigh(k, v)
+	if rval, ok = self.hash.Put(k, v); ok {
+		delta -= self.weigh(k, rval)
+	}
+	atomic.AddInt64(&self.weight, delta)
+	self.evict()
+	return
+}
+
+// PutIfMissing will insert v under k if k was missing from the Cache, evicting other entries if it becomes too big, and return whether it inserted anything.
+func (self *Cache) PutIfMissing(k Key, v unsafe.Pointer) (rval bool) {
+	if rval = self.hash.PutIfMissing(k, v); rval {
+		atomic.AddInt64(&self.weight, self.weigh(k, v))
+		self.evict()
+	}
+	return
+}
+
+// Delete will remove k from the Cache and return the removed value and whether any value was removed.
+func (self *Cache) Delete(k Key) (rval unsafe.Pointer, ok bool) {
+	if rval, ok = self.hash.Delete(k); ok {
+		atomic.AddInt64(&self.weight, -self.weigh(k, rval))
+	}
+	return
+}
+
+func (self *Cache) full() bool {
+	return (self.options.MaxEntries > 0 && self.hash.Size() > self.options.MaxEntries) ||
+		(self.options.MaxWeight > 0 && atomic.LoadInt64(&self.weight) > self.options.MaxWeight)
+}
+
+func (self *Cache) evict() {
+	for self.full() && self.evictOne() {
+	}
+}
+
+// evictOne moves the clock hand until it has evicted an entry, and
+// returns false if the Cache was empty. Two turns of the hand are
+// enough, since the first one clears all reference bits.
+func (self *Cache) evictOne() (rval bool) {
+	visit := func(n *element) bool {
+		e := &n.entry
+		if !e.real() {
+			return false
+		}
+		v := e.val()
+		if v == deletedValue {
+			return false
+		}
+		if atomic.LoadUint32(referenced(n)) == 1 {
+			atomic.StoreUint32(referenced(n), 0)
+			return false
+		}
+		if !atomic.CompareAndSwapPointer(&e.value, v, deletedValue) {
+			return false
+		}
+		self.hash.remove(&entry{hashCode: e.hashCode, hashKey: e.hashKey, key: e.key}, n)
+		atomic.StoreUint64(&self.hand, e.hashKey+1)
+		atomic.AddInt64(&self.weight, -self.weigh(e.key, v))
+		if self.options.OnEvict != nil {
+			self.options.OnEvict(e.key, v)
+		}
+		rval = true
+		return true
+	}
+	hand := atomic.LoadUint64(&self.hand)
+	for turn := 0; turn < 2 && !rval; turn++ {
+		if !self.hash.eachElementBetween(hand, 0, visit) && hand != 0 {
+			self.hash.eachElementBetween(0, hand, visit)
+		}
+	}
+	return
+}
@@ -0,0 +1,87 @@
+package gotomic
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestCache(t *testing.T) {
+	var evicted []Key
+	c := NewCache(CacheOptions{MaxEntries: 10, OnEvict: func(k Key, v unsafe.Pointer) {
+		evicted = append(evicted, k)
+	}})
+	v := "v"
+	for i := 0; i < 10; i++ {
+		c.Put(MakeKey(uint64(i)), unsafe.Pointer(&v))
+	}
+	if c.Size() != 10 || len(evicted) != 0 {
+		t.Error(c, "should contain 10 entries without evicting any")
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := c.Get(MakeKey(uint64(i))); !ok {
+			t.Error(c, "should contain", i)
+		}
+	}
+	for i := 10; i < 15; i++ {
+		c.Put(MakeKey(uint64(i)), unsafe.Pointer(&v))
+	}
+	if c.Size() != 10 || len(evicted) != 5 {
+		t.Error(c, "should contain 10 entries after evicting 5, but contained", c.Size(), "after evicting", len(evicted))
+	}
+	for _, k := range evicted {
+		if i := k[0]; i < 5 {
+			t.Error(c, "should not evict referenced", i)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := c.Get(MakeKey(uint64(i))); !ok {
+			t.Error(c, "should still contain", i)
+		}
+	}
+	if _, ok := c.Delete(MakeKey(0)); !ok || c.Weight() != 9 {
+		t.Error(c, "should delete 0 and weigh 9, but weighed", c.Weight())
+	}
+	fmt.Println("...Done TestCache")
+}
+
+func TestCacheWeight(t *testing.T) {
+	c := NewCache(CacheOptions{MaxWeight: 100, Weigher: func(k Key, v unsafe.Pointer) int64 {
+		return int64(len(*(*string)(v)))
+	}})
+	small, big := "0123456789", string(make([]byte, 60))
+	for i := 0; i < 10; i++ {
+		c.Put(MakeKey(uint64(i)), unsafe.Pointer(&small))
+	}
+	if c.Weight() != 100 || c.Size() != 10 {
+		t.Error(c, "should weigh 100, but weighed", c.Weight())
+	}
+	c.Put(MakeKey(3), unsafe.Pointer(&big))
+	if c.Weight() > 100 || c.Size() != 5 {
+		t.Error(c, "should evict 5 small entries for the big one, but weighed", c.Weight(), "with size", c.Size())
+	}
+	fmt.Println("...Done TestCacheWeight")
+}
+
+func TestCacheConcurrency(t *testing.T) {
+	c := NewCache(CacheOptions{MaxEntries: 100})
+	v := "v"
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Put(MakeKey(uint64(i*1000+j)), unsafe.Pointer(&v))
+				c.Get(MakeKey(uint64(i*1000 + j/2)))
+			}
+		}(i)
+	}
+	wg.Wait()
+	if c.Size() > 100 || int64(c.Size()) != c.Weight() {
+		t.Error(c, "should contain at most 100 entries and weigh as many, but contained", c.Size(), "and weighed", c.Weight())
+	}
+	fmt.Println("...Done TestCacheConcurrency")
+}
@@ -0,0 +1,85 @@
+package gotomic
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ValueCodec translates the values of a Hash, which are opaque
+// pointers, to and from bytes.
+type ValueCodec interface {
+	EncodeValue(v unsafe.Pointer) ([]byte, error)
+	DecodeValue(b []byte) (unsafe.Pointer, error)
+}
+
+var registeredValueCodec atomic.Value
+
+type registeredCodec struct {
+	codec ValueCodec
+}
+
+// RegisterValueCodec makes codec the ValueCodec of all Hashes created
+// without one of their own, including zero Hashes that are decoded
+// into with UnmarshalJSON or GobDecode.
+func RegisterValueCodec(codec ValueCodec) {
+	registeredValueCodec.Store(registeredCodec{codec})
+}
+
+// valueCodec returns the ValueCodec of the Hash, or the registered one.
+func (self *Hash) valueCodec() ValueCodec {
+	if self.codec != nil {
+		return self.codec
+	}
+	registered, _ := registeredValueCodec.Load().(registeredCodec)
+	return registered.codec
+}
+
+type gobValueCodec[T any] struct{}
+
+// GobValueCodec returns a ValueCodec for values that are *T, encoded
+// with encoding/gob.
+func GobValueCodec[T any]() ValueCodec {
+	return gobValueCodec[T]{}
+}
+
+func (self gobValueCodec[T]) EncodeValue(v unsafe.Pointer) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode((*T)(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (self gobValueCodec[T]) DecodeValue(b []byte) (unsafe.Pointer, error) {
+	v := new(T)
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(v); err != nil {
+		return nil, err
+	}
+	return unsafe.Pointer(v), nil
+}
+
+type jsonValueCodec[T any] struct{}
+
+// JSONValueCodec returns a ValueCodec for values that are *T, encoded
+// with encoding/json. Hash.MarshalJSON embeds the values it encodes as
+// they are, instead of as base64 strings.
+func JSONValueCodec[T any]() ValueCodec {
+	return jsonValueCodec[T]{}
+}
+
+func (self jsonValueCodec[T]) EncodeValue(v unsafe.Pointer) ([]byte, error) {
+	return json.Marshal((*T)(v))
+}
+
+func (self jsonValueCodec[T]) DecodeValue(b []byte) (unsafe.Pointer, error) {
+	v := new(T)
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	return unsafe.Pointer(v), nil
+}
+
+func (self jsonValueCodec[T]) encodesJSON() {}
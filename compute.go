@@ -0,0 +1,102 @@
+package gotomic
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// ComputeOp tells Compute what to do with the value returned by a ComputeFunc.
+type ComputeOp int
+
+const (
+	// ComputeKeep leaves k as it was, present or not.
+	ComputeKeep ComputeOp = iota
+	// ComputeReplace stores the new value under k.
+	ComputeReplace
+	// ComputeInsert stores the new value under k. It is the same as
+	// ComputeReplace, and reads better when k was missing.
+	ComputeInsert
+	// ComputeDelete removes k.
+	ComputeDelete
+)
+
+// ComputeFunc gets the current value under a key and whether there was
+// one, and returns the new value and what to do with it.
+type ComputeFunc func(old unsafe.Pointer, loaded bool) (new unsafe.Pointer, op ComputeOp)
+
+// Compute atomically replaces the value under k with the outcome of f,
+// and returns the value under k afterwards and whether there is one.
+//
+// f runs without any locks held, and is called again with the new
+// current value whenever another goroutine changes k before the outcome
+// of f could be applied. It must therefore be free of side effects.
+func (self *Hash) Compute(k Key, f ComputeFunc) (rval unsafe.Pointer, ok bool) {
+	testEntry := newRealEntryWithHashCode(k, nil, self.hashCode(k))
+	alloc := self.newElement()
+	for {
+		bucket := self.getBucketByHashCode(testEntry.hashCode)
+		hit := (*hashHit)(self.search(bucket, *testEntry, ReusableHit()))
+		tmp := &hashHit{hit.left, hit.element, hit.right}
+		hit2 := hit.search(testEntry, tmp, &self.keys)
+		if hit2.element == nil {
+			newValue, op := f(nil, false)
+			if op == ComputeKeep || op == ComputeDelete {
+				return nil, false
+			}
+			newEntry := *testEntry
+			newEntry.value = newValue
+			if hit2.left.addBefore(newEntry, alloc, hit2.right) {
+				self.addSize(1)
+				return newValue, true
+			}
+			self.stats.inc(stat_add_before_retries)
+			continue
+		}
+		oldEntry := &hit2.element.entry
+		oldValuePtr := atomic.LoadPointer(&oldEntry.value)
+		if oldValuePtr == deletedValue {
+			hit2.element.doRemove()
+			continue
+		}
+		if self.expire(hit2.element, oldValuePtr) {
+			continue
+		}
+		newValue, op := f(oldValuePtr, true)
+		switch op {
+		case ComputeKeep:
+			return oldValuePtr, true
+		case ComputeDelete:
+			if atomic.CompareAndSwapPointer(&oldEntry.value, oldValuePtr, deletedValue) {
+				self.remove(testEntry, hit2.element)
+				return nil, false
+			}
+		default:
+			if atomic.CompareAndSwapPointer(&oldEntry.value, oldValuePtr, newValue) {
+				return newValue, true
+			}
+		}
+	}
+}
+
+// ComputeIfAbsent returns the value under k if there is one, and otherwise inserts and returns the value produced by f.
+// loaded tells whether the value was already there. f may be called even if another goroutine inserts k first.
+func (self *Hash) ComputeIfAbsent(k Key, f func() unsafe.Pointer) (actual unsafe.Pointer, loaded bool) {
+	actual, _ = self.Compute(k, func(old unsafe.Pointer, ok bool) (unsafe.Pointer, ComputeOp) {
+		loaded = ok
+		if ok {
+			return old, ComputeKeep
+		}
+		return f(), ComputeInsert
+	})
+	return
+}
+
+// ComputeIfPresent is Compute for when k is present. If k is missing, f is not called and nothing happens.
+func (self *Hash) ComputeIfPresent(k Key, f func(old unsafe.Pointer) (unsafe.Pointer, ComputeOp)) (unsafe.Pointer, bool) {
+	return self.Compute(k, func(old unsafe.Pointer, ok bool) (unsafe.Pointer, ComputeOp) {
+		if !ok {
+			return nil, ComputeKeep
+		}
+		return f(old)
+	})
+}
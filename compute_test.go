@@ -0,0 +1,90 @@
+package gotomic
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestHashCompute(t *testing.T) {
+	h := NewHash()
+	v1, v2 := "v1", "v2"
+	if v, ok := h.Compute(MakeKey(1), func(old unsafe.Pointer, loaded bool) (unsafe.Pointer, ComputeOp) {
+		if loaded {
+			t.Error(h, "should not contain 1")
+		}
+		return unsafe.Pointer(&v1), ComputeKeep
+	}); ok || v != nil {
+		t.Error(h, "should still not contain 1, got", v, ok)
+	}
+	if v, ok := h.Compute(MakeKey(1), func(old unsafe.Pointer, loaded bool) (unsafe.Pointer, ComputeOp) {
+		return unsafe.Pointer(&v1), ComputeInsert
+	}); !ok || v != unsafe.Pointer(&v1) {
+		t.Error(h, "should contain 1 => v1, got", v, ok)
+	}
+	if v, ok := h.Compute(MakeKey(1), func(old unsafe.Pointer, loaded bool) (unsafe.Pointer, ComputeOp) {
+		if !loaded || old != unsafe.Pointer(&v1) {
+			t.Error(h, "should contain 1 => v1")
+		}
+		return unsafe.Pointer(&v2), ComputeReplace
+	}); !ok || v != unsafe.Pointer(&v2) {
+		t.Error(h, "should contain 1 => v2, got", v, ok)
+	}
+	if v, ok := h.ComputeIfAbsent(MakeKey(1), func() unsafe.Pointer {
+		t.Error(h, "should not call f for a present key")
+		return nil
+	}); !ok || v != unsafe.Pointer(&v2) {
+		t.Error(h, "should contain 1 => v2, got", v, ok)
+	}
+	if v, ok := h.ComputeIfPresent(MakeKey(1), func(old unsafe.Pointer) (unsafe.Pointer, ComputeOp) {
+		return nil, ComputeDelete
+	}); ok || v != nil {
+		t.Error(h, "should have deleted 1, got", v, ok)
+	}
+	if h.Size() != 0 {
+		t.Error(h, "should be empty but had size", h.Size())
+	}
+	if _, ok := h.ComputeIfPresent(MakeKey(1), func(old unsafe.Pointer) (unsafe.Pointer, ComputeOp) {
+		t.Error(h, "should not call f for a missing key")
+		return nil, ComputeReplace
+	}); ok {
+		t.Error(h, "should not contain 1")
+	}
+	if v, loaded := h.ComputeIfAbsent(MakeKey(1), func() unsafe.Pointer {
+		return unsafe.Pointer(&v1)
+	}); loaded || v != unsafe.Pointer(&v1) {
+		t.Error(h, "should have inserted 1 => v1, got", v, loaded)
+	}
+	fmt.Println("...Done TestHashCompute")
+}
+
+func TestHashComputeConcurrency(t *testing.T) {
+	h := NewHash()
+	n := 1000
+	workers := runtime.NumCPU() + 1
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				h.Compute(MakeKey(uint64(j%10)), func(old unsafe.Pointer, loaded bool) (unsafe.Pointer, ComputeOp) {
+					count := 1
+					if loaded {
+						count += *(*int)(old)
+					}
+					return unsafe.Pointer(&count), ComputeReplace
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	for j := 0; j < 10; j++ {
+		if v, _ := h.Get(MakeKey(uint64(j))); *(*int)(v) != workers*n/10 {
+			t.Error(h, "should have counted", j, "to", workers*n/10, "but got", *(*int)(v))
+		}
+	}
+	fmt.Println("...Done TestHashComputeConcurrency")
+}
@@ -0,0 +1,118 @@
+package gotomic
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+type CounterHashIterator func(k Key, v int64) bool
+
+// CounterHash is a Hash of int64 counters, updated in place with
+// sync/atomic.
+//
+// Each counter is allocated together with the element holding it, and
+// the value of the entry points at the counter. A key costs one
+// allocation when it is first used, and updating it after that costs
+// none. To keep it that way the keys are hashed with MixHash, which
+// unlike the default crc32 does not make the key escape.
+type CounterHash struct {
+	hash *Hash
+}
+
+type counterElement struct {
+	element element
+	count   int64
+}
+
+func NewCounterHash() *CounterHash {
+	return &CounterHash{hash: NewHashWithOptions(HashOptions{HashFunc: MixHash})}
+}
+
+func (self *CounterHash) Size() int {
+	return self.hash.Size()
+}
+
+// Each will run i on each key and counter, and return true if the iteration was interrupted.
+func (self *CounterHash) Each(i CounterHashIterator) bool {
+	return self.hash.Each(func(k Key, v unsafe.Pointer) bool {
+		return i(k, atomic.LoadInt64((*int64)(v)))
+	})
+}
+
+// ToMap returns a map[Key]int64 that is logically identical to the CounterHash.
+func (self *CounterHash) ToMap() map[Key]int64 {
+	rval := make(map[Key]int64)
+	self.Each(func(k Key, v int64) bool {
+		rval[k] = v
+		return false
+	})
+	return rval
+}
+
+func (self *CounterHash) get(hashCode uint64, k Key) *int64 {
+	var te entry
+	var hh hashHit
+	var h hit
+	if v, ok := self.hash.GetHC64(hashCode, k, &LocalData{te: &te, hh: &hh, hit: &h}); ok {
+		return (*int64)(v)
+	}
+	return nil
+}
+
+// counter returns the counter at k, inserting a zero one if k is missing.
+func (self *CounterHash) counter(k Key) *int64 {
+	hashCode := self.hash.hashCode(k)
+	for {
+		if rval := self.get(hashCode, k); rval != nil {
+			return rval
+		}
+		alloc := &counterElement{}
+		if self.hash.putIfMissingElement(newRealEntryWithHashCode(k, unsafe.Pointer(&alloc.count), hashCode), &alloc.element) {
+			return &alloc.count
+		}
+	}
+}
+
+// Add will add delta to the counter at k, inserting it if k is missing, and return the new value.
+func (self *CounterHash) Add(k Key, delta int64) int64 {
+	return atomic.AddInt64(self.counter(k), delta)
+}
+
+// Load returns the counter at k and whether it was present in the CounterHash.
+func (self *CounterHash) Load(k Key) (int64, bool) {
+	if c := self.get(self.hash.hashCode(k), k); c != nil {
+		return atomic.LoadInt64(c), true
+	}
+	return 0, false
+}
+
+// CompareAndSwap will set the counter at k to new if it is old, and return whether it set anything.
+// A missing counter is treated as 0, and only inserted if old is 0.
+func (self *CounterHash) CompareAndSwap(k Key, old, new int64) bool {
+	c := self.get(self.hash.hashCode(k), k)
+	if c == nil {
+		if old != 0 {
+			return false
+		}
+		c = self.counter(k)
+	}
+	return atomic.CompareAndSwapInt64(c, old, new)
+}
+
+// Reset will set the counter at k to 0, if k is present, and return the previous value and whether k was present.
+// Unlike Delete it keeps the entry, so no concurrent Add is lost.
+func (self *CounterHash) Reset(k Key) (int64, bool) {
+	if c := self.get(self.hash.hashCode(k), k); c != nil {
+		return atomic.SwapInt64(c, 0), true
+	}
+	return 0, false
+}
+
+// Delete will remove k from the CounterHash and return the removed counter and whether any counter was removed.
+// An Add that found the counter before it was removed may update it after it is returned, and be lost.
+func (self *CounterHash) Delete(k Key) (int64, bool) {
+	if v, ok := self.hash.Delete(k); ok {
+		return atomic.LoadInt64((*int64)(v)), true
+	}
+	return 0, false
+}
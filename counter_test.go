@@ -0,0 +1,85 @@
+package gotomic
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestCounterHash(t *testing.T) {
+	h := NewCounterHash()
+	if v, ok := h.Load(MakeKey(1)); ok || v != 0 {
+		t.Error(h, "should not contain 1")
+	}
+	if v := h.Add(MakeKey(1), 3); v != 3 {
+		t.Error(h, "should add 3 to 1 and return 3, got", v)
+	}
+	if v := h.Add(MakeKey(1), -1); v != 2 {
+		t.Error(h, "should add -1 to 1 and return 2, got", v)
+	}
+	if v, ok := h.Load(MakeKey(1)); !ok || v != 2 {
+		t.Error(h, "should contain 1 => 2")
+	}
+	if h.CompareAndSwap(MakeKey(1), 3, 4) {
+		t.Error(h, "should not swap 1 from 3")
+	}
+	if !h.CompareAndSwap(MakeKey(1), 2, 4) {
+		t.Error(h, "should swap 1 from 2 to 4")
+	}
+	if !h.CompareAndSwap(MakeKey(2), 0, 5) {
+		t.Error(h, "should insert 2 and swap it from 0 to 5")
+	}
+	if size := h.Size(); h.CompareAndSwap(MakeKey(3), 1, 5) || h.Size() != size {
+		t.Error(h, "should neither swap nor insert 3 from 1")
+	}
+	if v, ok := h.Reset(MakeKey(1)); !ok || v != 4 {
+		t.Error(h, "should reset 1 from 4")
+	}
+	if v, ok := h.Reset(MakeKey(3)); ok || v != 0 {
+		t.Error(h, "should not reset missing 3")
+	}
+	if m := h.ToMap(); len(m) != 2 || m[MakeKey(1)] != 0 || m[MakeKey(2)] != 5 {
+		t.Error(h, "should contain 1 => 0 and 2 => 5, got", m)
+	}
+	if v, ok := h.Delete(MakeKey(2)); !ok || v != 5 {
+		t.Error(h, "should delete 2 => 5")
+	}
+	if h.Size() != 1 {
+		t.Error(h, "should have size 1 but had", h.Size())
+	}
+	fmt.Println("...Done TestCounterHash")
+}
+
+func TestCounterHashConcurrency(t *testing.T) {
+	h := NewCounterHash()
+	cmp := 1000
+	n := runtime.NumCPU()
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < cmp; j++ {
+				h.Add(MakeKey(uint64(j%10)), 1)
+			}
+		}()
+	}
+	wg.Wait()
+	for j := 0; j < 10; j++ {
+		if v, _ := h.Load(MakeKey(uint64(j))); v != int64(n*cmp/10) {
+			t.Error(h, "should contain", j, "=>", n*cmp/10, "but had", v)
+		}
+	}
+	fmt.Println("...Done TestCounterHashConcurrency")
+}
+
+func TestCounterHashAllocs(t *testing.T) {
+	h := NewCounterHash()
+	k := MakeKey(1)
+	h.Add(k, 1)
+	if allocs := testing.AllocsPerRun(100, func() { h.Add(k, 1) }); allocs != 0 {
+		t.Error("Add to an existing counter should not allocate, but did", allocs, "times")
+	}
+	fmt.Println("...Done TestCounterHashAllocs")
+}
@@ -0,0 +1,131 @@
+package gotomic
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"unsafe"
+)
+
+// hashDescription is the structure of a Hash, as written by DescribeJSON
+// and DescribeDOT.
+type hashDescription struct {
+	Size     int    `json:"size"`
+	Exponent uint32 `json:"exponent"`
+	// Elements are the elements of the list, in order.
+	Elements []elementDescription `json:"elements"`
+	// Buckets are the initialised slots of the bucket directory.
+	Buckets []bucketDescription `json:"buckets"`
+}
+
+type elementDescription struct {
+	HashCode uint64 `json:"hash_code"`
+	HashKey  uint64 `json:"hash_key"`
+	Sentinel bool   `json:"sentinel"`
+	// Key is the key of a real entry, in hex.
+	Key string `json:"key,omitempty"`
+}
+
+type bucketDescription struct {
+	Index      uint64 `json:"index"`
+	SuperIndex uint64 `json:"super_index"`
+	SubIndex   uint64 `json:"sub_index"`
+	// Element is the position in Elements of the sentinel of the bucket.
+	Element int `json:"element"`
+}
+
+// describe returns the structure of the Hash. Sentinels left in the
+// list by shrink, that no bucket points at, are included in Elements
+// but not in Buckets.
+func (self *Hash) describe() (rval hashDescription) {
+	rval.Size = self.Size()
+	rval.Exponent = atomic.LoadUint32(&self.exponent)
+	rval.Elements = []elementDescription{}
+	rval.Buckets = []bucketDescription{}
+	self.eachElementBetween(0, 0, func(n *element) bool {
+		e := &n.entry
+		d := elementDescription{HashCode: e.hashCode, HashKey: e.hashKey, Sentinel: !e.real()}
+		if e.real() {
+			d.Key = hex.EncodeToString(e.key[:])
+		} else if superIndex, subIndex := self.getBucketIndices(e.hashCode); superIndex <= uint64(rval.Exponent) {
+			if p := atomic.LoadPointer(&self.buckets[superIndex]); p != nil {
+				if atomic.LoadPointer(&(*(*[]unsafe.Pointer)(p))[subIndex]) == unsafe.Pointer(n) {
+					rval.Buckets = append(rval.Buckets, bucketDescription{Index: e.hashCode, SuperIndex: superIndex, SubIndex: subIndex, Element: len(rval.Elements)})
+				}
+			}
+		}
+		rval.Elements = append(rval.Elements, d)
+		return false
+	})
+	return
+}
+
+// DescribeJSON writes the structure of the Hash to w as JSON: the
+// elements of the split-ordered list, sentinels and real entries, in
+// order, and the slots of the bucket directory with the sentinels they
+// point at.
+func (self *Hash) DescribeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(self.describe())
+}
+
+// DescribeDOT writes the structure of the Hash to w as a Graphviz graph,
+// with the split-ordered list in a row, and the two level bucket
+// directory pointing into it from above.
+func (self *Hash) DescribeDOT(w io.Writer) (err error) {
+	d := self.describe()
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	printf("digraph gotomic {\n")
+	printf("\tlabel=\"size %v, exponent %v\";\n", d.Size, d.Exponent)
+	printf("\tnode [fontname=monospace];\n")
+	printf("\tsubgraph list {\n\t\trank=same;\n")
+	for i, e := range d.Elements {
+		if e.Sentinel {
+			printf("\t\te%v [shape=box, label=\"bucket %v\\n%0.16x\"];\n", i, e.HashCode, e.HashKey)
+		} else {
+			printf("\t\te%v [shape=ellipse, label=\"%v\\n%0.16x\"];\n", i, e.Key, e.HashKey)
+		}
+		if i > 0 {
+			printf("\t\te%v -> e%v;\n", i-1, i)
+		}
+	}
+	printf("\t}\n")
+	supers := make(map[uint64][]bucketDescription)
+	for _, b := range d.Buckets {
+		supers[b.SuperIndex] = append(supers[b.SuperIndex], b)
+	}
+	printf("\tdirectory [shape=record, label=\"")
+	for super := uint64(0); super <= uint64(d.Exponent); super++ {
+		if super > 0 {
+			printf("|")
+		}
+		printf("<s%v>%v", super, super)
+	}
+	printf("\"];\n")
+	for super := uint64(0); super <= uint64(d.Exponent); super++ {
+		if len(supers[super]) == 0 {
+			continue
+		}
+		printf("\tsuper%v [shape=record, label=\"", super)
+		for i, b := range supers[super] {
+			if i > 0 {
+				printf("|")
+			}
+			printf("<b%v>%v", b.SubIndex, b.SubIndex)
+		}
+		printf("\"];\n")
+		printf("\tdirectory:s%v -> super%v;\n", super, super)
+		for _, b := range supers[super] {
+			printf("\tsuper%v:b%v -> e%v [style=dashed];\n", super, b.SubIndex, b.Element)
+		}
+	}
+	printf("}\n")
+	return
+}
@@ -0,0 +1,76 @@
+package gotomic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestHashDescribeJSON(t *testing.T) {
+	h := NewHash()
+	v := "v"
+	for i := 0; i < 10; i++ {
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&v))
+	}
+	buf := new(bytes.Buffer)
+	if err := h.DescribeJSON(buf); err != nil {
+		t.Error(h, "should describe itself as JSON, got", err)
+	}
+	var d hashDescription
+	if err := json.Unmarshal(buf.Bytes(), &d); err != nil {
+		t.Error(h, "should describe itself as valid JSON, got", err)
+	}
+	if d.Size != 10 || d.Exponent != h.exponent {
+		t.Error(h, "should describe size 10 and exponent", h.exponent, "but described", d.Size, d.Exponent)
+	}
+	reals := 0
+	for i, e := range d.Elements {
+		if i > 0 && e.HashKey < d.Elements[i-1].HashKey {
+			t.Error(h, "should describe its elements in order, got", d.Elements)
+		}
+		if !e.Sentinel {
+			reals++
+		}
+	}
+	if reals != 10 {
+		t.Error(h, "should describe 10 real entries, but described", reals)
+	}
+	for _, b := range d.Buckets {
+		if e := d.Elements[b.Element]; !e.Sentinel || e.HashCode != b.Index {
+			t.Error(h, "should describe bucket", b.Index, "pointing at its sentinel, but it pointed at", e)
+		}
+		if super, sub := h.getBucketIndices(b.Index); super != b.SuperIndex || sub != b.SubIndex {
+			t.Error(h, "should describe bucket", b.Index, "at", super, sub, "but described it at", b.SuperIndex, b.SubIndex)
+		}
+	}
+	if len(d.Buckets) == 0 {
+		t.Error(h, "should describe some buckets")
+	}
+	fmt.Println("...Done TestHashDescribeJSON")
+}
+
+func TestHashDescribeDOT(t *testing.T) {
+	h := NewHash()
+	v := "v"
+	for i := 0; i < 10; i++ {
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&v))
+	}
+	buf := new(bytes.Buffer)
+	if err := h.DescribeDOT(buf); err != nil {
+		t.Error(h, "should describe itself as DOT, got", err)
+	}
+	dot := buf.String()
+	if !strings.HasPrefix(dot, "digraph gotomic {\n") || !strings.HasSuffix(dot, "}\n") {
+		t.Error(h, "should describe itself as a digraph, got", dot)
+	}
+	if n := strings.Count(dot, "shape=ellipse"); n != 10 {
+		t.Error(h, "should describe 10 real entries, but described", n)
+	}
+	if !strings.Contains(dot, "directory:s0 -> super0;") || !strings.Contains(dot, "super0:b0 -> e0 [style=dashed];") {
+		t.Error(h, "should describe bucket 0 pointing at the first element, got", dot)
+	}
+	fmt.Println("...Done TestHashDescribeDOT")
+}
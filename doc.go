@@ -0,0 +1,7 @@
+// Package gotomic is a non blocking hash table, built on a
+// split-ordered list, with read performance close to that of a native
+// map but without a RWMutex.
+//
+// The package needs Go 1.23, for iter. TypedHash also needs Go 1.24,
+// for maphash.Comparable, and is left out of older builds.
+package gotomic
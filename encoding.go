@@ -0,0 +1,129 @@
+package gotomic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// jsonEncoder is implemented by the ValueCodecs whose encoded values are
+// JSON themselves.
+type jsonEncoder interface {
+	encodesJSON()
+}
+
+// MarshalJSON encodes the Hash as a JSON object, with the keys as hex
+// strings and the values encoded by the ValueCodec of the Hash. Values
+// from a JSONValueCodec are embedded as they are, and other values as
+// base64 strings.
+func (self *Hash) MarshalJSON() ([]byte, error) {
+	codec := self.valueCodec()
+	if codec == nil {
+		return nil, ErrNoValueCodec
+	}
+	_, raw := codec.(jsonEncoder)
+	buf := bytes.NewBufferString("{")
+	var err error
+	self.eachEntry(func(e *entry, v unsafe.Pointer) bool {
+		var b []byte
+		if b, err = codec.EncodeValue(v); err != nil {
+			return true
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, "%q:", hex.EncodeToString(e.key[:]))
+		if raw {
+			buf.Write(b)
+		} else {
+			b, _ = json.Marshal(b)
+			buf.Write(b)
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// decodeKey decodes a key encoded as a hex or base64 string.
+func decodeKey(s string) (k Key, err error) {
+	var b []byte
+	if len(s) == 2*len(k) {
+		b, err = hex.DecodeString(s)
+	} else {
+		b, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err == nil && len(b) != len(k) {
+		err = fmt.Errorf("gotomic: key %q is %v bytes, not %v", s, len(b), len(k))
+	}
+	copy(k[:], b)
+	return
+}
+
+// UnmarshalJSON adds the entries of a JSON object encoded by MarshalJSON
+// to the Hash, with the values decoded by the ValueCodec of the Hash.
+// Keys may be hex or base64 strings. Like ReadFrom, it adds nothing
+// unless all entries decode, and uses BulkLoad if the Hash is empty.
+func (self *Hash) UnmarshalJSON(b []byte) error {
+	self.initIfZero()
+	codec := self.valueCodec()
+	if codec == nil {
+		return ErrNoValueCodec
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	_, raw := codec.(jsonEncoder)
+	pairs := make([]bulkPair, 0, len(m))
+	for s, rawValue := range m {
+		k, err := decodeKey(s)
+		if err != nil {
+			return err
+		}
+		encoded := []byte(rawValue)
+		if !raw {
+			if err := json.Unmarshal(rawValue, &encoded); err != nil {
+				return err
+			}
+		}
+		v, err := codec.DecodeValue(encoded)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, bulkPair{k, v})
+	}
+	self.bulkLoad(pairs, runtime.NumCPU())
+	return nil
+}
+
+// GobEncode encodes the Hash as a snapshot, like WriteTo.
+func (self *Hash) GobEncode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, err := self.WriteTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode adds the entries of a snapshot encoded by GobEncode to the
+// Hash, like ReadFrom.
+func (self *Hash) GobDecode(b []byte) error {
+	_, err := self.ReadFrom(bytes.NewReader(b))
+	return err
+}
+
+// initIfZero makes a zero Hash, like the ones the decoders allocate,
+// the same as one from NewHash.
+func (self *Hash) initIfZero() {
+	if self.buckets == nil {
+		*self = *NewHash()
+	}
+}
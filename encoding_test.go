@@ -0,0 +1,92 @@
+package gotomic
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+type encodingFixture struct {
+	Name   string
+	Values *Hash
+}
+
+func TestHashJSON(t *testing.T) {
+	h := NewHashWithOptions(HashOptions{ValueCodec: JSONValueCodec[string]()})
+	v1, v2 := "v1", "v2"
+	h.Put(MakeKey(1), unsafe.Pointer(&v1))
+	h.Put(MakeKey(2), unsafe.Pointer(&v2))
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(h, "should marshal, got", err)
+	}
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil || m["01000000000000000000000000000000"] != "v1" || m["02000000000000000000000000000000"] != "v2" {
+		t.Error(h, "should marshal to a map from hex keys to values, got", string(b), err)
+	}
+	if h.String() != string(b) {
+		t.Error(h, "should print as JSON")
+	}
+	h2 := NewHashWithOptions(HashOptions{ValueCodec: JSONValueCodec[string]()})
+	if err := json.Unmarshal([]byte(`{"01000000000000000000000000000000":"v1","AgAAAAAAAAAAAAAAAAAAAA==":"v2"}`), h2); err != nil {
+		t.Error(h2, "should unmarshal hex and base64 keys, got", err)
+	}
+	if v, ok := h2.Get(MakeKey(1)); !ok || *(*string)(v) != "v1" {
+		t.Error(h2, "should contain 1 => v1")
+	}
+	if v, ok := h2.Get(MakeKey(2)); !ok || *(*string)(v) != "v2" {
+		t.Error(h2, "should contain 2 => v2")
+	}
+	h3 := NewHashWithOptions(HashOptions{ValueCodec: GobValueCodec[string]()})
+	h3.Put(MakeKey(1), unsafe.Pointer(&v1))
+	if b, err = json.Marshal(h3); err != nil {
+		t.Fatal(h3, "should marshal, got", err)
+	}
+	h4 := NewHashWithOptions(HashOptions{ValueCodec: GobValueCodec[string]()})
+	if err := json.Unmarshal(b, h4); err != nil {
+		t.Error(h4, "should unmarshal base64 values, got", err)
+	}
+	if v, ok := h4.Get(MakeKey(1)); !ok || *(*string)(v) != "v1" {
+		t.Error(h4, "should contain 1 => v1")
+	}
+	if _, err := json.Marshal(NewHash()); err == nil {
+		t.Error("should not marshal without a ValueCodec")
+	}
+	fmt.Println("...Done TestHashJSON")
+}
+
+func TestHashGob(t *testing.T) {
+	RegisterValueCodec(GobValueCodec[string]())
+	defer RegisterValueCodec(nil)
+	fixture := encodingFixture{Name: "fixture", Values: NewHash()}
+	for i := 0; i < 100; i++ {
+		s := fmt.Sprint(i)
+		fixture.Values.Put(MakeKey(uint64(i)), unsafe.Pointer(&s))
+	}
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(fixture); err != nil {
+		t.Fatal("should gob encode, got", err)
+	}
+	var decoded encodingFixture
+	if err := gob.NewDecoder(buf).Decode(&decoded); err != nil {
+		t.Fatal("should gob decode, got", err)
+	}
+	if decoded.Name != "fixture" || decoded.Values.Size() != 100 {
+		t.Error(decoded.Values, "should have size 100")
+	}
+	for i := 0; i < 100; i++ {
+		if v, ok := decoded.Values.Get(MakeKey(uint64(i))); !ok || *(*string)(v) != fmt.Sprint(i) {
+			t.Error(decoded.Values, "should contain", i)
+		}
+	}
+	if err := decoded.Values.Verify(); err != nil {
+		t.Error(decoded.Values, "should verify, got", err)
+	}
+	if s := decoded.Values.String(); s != fmt.Sprint(decoded.Values.ToMap()) {
+		t.Error("should not print as JSON with only a registered codec, got", s)
+	}
+	fmt.Println("...Done TestHashGob")
+}
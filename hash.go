@@ -2,15 +2,21 @@ package gotomic
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
-	"hash/crc32"
+	"math/bits"
 	"sync/atomic"
 	"unsafe"
 )
 
-const max_exponent = 32
+const max_exponent = 64
+const max_exponent_32 = 32
 const default_load_factor = 0.5
 
+// The table shrinks when its size drops below the load factor divided
+// by this, so that it doesn't flap between growing and shrinking.
+const shrink_hysteresis = 4
+
 type HashIterator func(k Key, v unsafe.Pointer) bool
 
 type Equalable interface {
@@ -21,7 +27,7 @@ type Equalable interface {
 type Key [16]byte
 
 func (k Key) HashCode() uint32 {
-	return crc32.ChecksumIEEE(k[:])
+	return CRC32Hash(k)
 }
 
 func (k Key) Equals(sk Key) bool {
@@ -29,17 +35,18 @@ func (k Key) Equals(sk Key) bool {
 }
 
 func MakeKey(x uint64) Key {
-	var i uint64
 	var b [16]byte
-	for i = 0; i < 8; i++ {
-		b[i] = byte((x >> (i * 8)))
-	}
+	binary.LittleEndian.PutUint64(b[:8], x)
 	return Key(b)
 }
 
+// deletedValue is swapped into the value of an entry to logically
+// delete it, before its element is marked and unlinked from the list.
+var deletedValue = unsafe.Pointer(new(byte))
+
 type entry struct {
-	hashCode uint32
-	hashKey  uint32
+	hashCode uint64
+	hashKey  uint64
 	key      Key
 	value    unsafe.Pointer
 }
@@ -48,6 +55,9 @@ type LocalData struct {
 	te  *entry
 	hh  *hashHit
 	hit *hit
+	// key is the *K the key of a lookup is put in, for hashes that keep
+	// their keys in value boxes.
+	key any
 }
 
 func InitLocalData() *LocalData {
@@ -73,36 +83,33 @@ func (hh *hashHit) Set(hh2 *hashHit) {
 	hh.right = hh2.right
 }
 
-func (te *entry) Set(hc uint32, k Key) {
+func (te *entry) Set(hc uint64, k Key) {
 	te.hashCode = hc
-	te.hashKey = reverse(hc) | 1
+	te.hashKey = reverse64(hc) | 1
 	te.key = k
 	te.value = nil
 }
 
-func newRealEntryWithHashCode(k Key, v unsafe.Pointer, hc uint32) *entry {
-	return &entry{hashCode: hc, hashKey: reverse(hc) | 1, key: k, value: v}
+func newRealEntryWithHashCode(k Key, v unsafe.Pointer, hc uint64) *entry {
+	return &entry{hashCode: hc, hashKey: reverse64(hc) | 1, key: k, value: v}
 }
 func newRealEntry(k Key, v unsafe.Pointer) *entry {
-	return newRealEntryWithHashCode(k, v, k.HashCode())
+	return newRealEntryWithHashCode(k, v, uint64(k.HashCode()))
 }
-func newMockEntry(hashCode uint32) *entry {
-	return &entry{hashCode: hashCode, hashKey: reverse(hashCode) &^ 1, value: nil}
+func newMockEntry(hashCode uint64) *entry {
+	return &entry{hashCode: hashCode, hashKey: reverse64(hashCode) &^ 1, value: nil}
 }
 func (self *entry) real() bool {
 	return self.hashKey&1 == 1
 }
 func (self *entry) val() unsafe.Pointer {
-	if self.value == nil {
-		return nil
-	}
 	k := atomic.LoadPointer(&self.value)
 	//return *(*Thing)(k)
 	return k
 	//	return *(*Thing)(atomic.LoadPointer(&self.value))
 }
 func (self *entry) String() string {
-	return fmt.Sprintf("&entry{%0.32b/%0.32b, %v=>%v}", self.hashCode, self.hashKey, self.key, self.val())
+	return fmt.Sprintf("&entry{%0.64b/%0.64b, %v=>%v}", self.hashCode, self.hashKey, self.key, self.val())
 }
 func (self *entry) Compare(e *entry) int {
 	if e == nil {
@@ -142,19 +149,155 @@ type Bucket struct {
 }
 
 type Hash struct {
-	exponent   uint32
-	buckets    []unsafe.Pointer
-	size       int64
-	loadFactor float64
+	// sentinels is the number of sentinel elements in the list. It
+	// comes first to be 64 bit aligned for sync/atomic on 32 bit
+	// platforms.
+	sentinels   int64
+	exponent    uint32
+	minExponent uint32
+	maxExponent uint32
+	buckets     []unsafe.Pointer
+	size        int64
+	loadFactor  float64
+	hashFunc    HashFunc
+	hashFunc64  HashFunc64
+	// loads is a *Hash with the in flight GetOrLoad calls, created when
+	// first needed.
+	loads unsafe.Pointer
+	// sweeper is the *sweeper started by StartSweeper, or nil.
+	sweeper unsafe.Pointer
+	// ttl is set if the elements of real entries are ttlElements.
+	ttl bool
+	// stats is nil unless HashOptions.Stats was set.
+	stats *hashStats
+	codec ValueCodec
+	keys  keyEquality
+	// elements, if not nil, allocates the elements of real entries, for
+	// hashes that keep something more next to each of them.
+	elements func() *element
+}
+
+// HashOptions configures a Hash created by NewHashWithOptions. The zero
+// value gives the same Hash as NewHash.
+type HashOptions struct {
+	// HashFunc calculates the hash codes of keys. Defaults to CRC32Hash.
+	HashFunc HashFunc
+	// Seeded makes the Hash use RandomSipHash instead of HashFunc, so
+	// that nobody can predict which keys will collide in it.
+	Seeded bool
+	// HashFunc64 makes the Hash use 64 bit hash codes, which lets the
+	// bucket directory grow beyond 2^32 buckets and keeps bucket scans
+	// short in tables with billions of entries. If set, HashFunc is
+	// ignored and the ...HC64 methods must be used instead of the ...HC
+	// methods, which panic.
+	HashFunc64 HashFunc64
+	// LoadFactor is the number of entries per bucket above which the
+	// bucket table grows. Defaults to 0.5.
+	LoadFactor float64
+	// Capacity is the number of entries the Hash is expected to hold.
+	// The bucket table starts out, and never shrinks below, the size
+	// needed for Capacity entries.
+	Capacity int
+	// Presize creates the sentinel elements of all buckets needed for
+	// Capacity entries up front, instead of when each bucket is first
+	// used.
+	Presize bool
+	// Stats makes the Hash count the events reported by Stats. It costs
+	// a few atomic increments per operation, so it is off by default.
+	Stats bool
+	// TTL makes the Hash keep an expiry time next to each entry, for
+	// PutWithTTL. It costs 8 bytes per entry, so it is off by default.
+	TTL bool
+	// ValueCodec translates the values of the Hash to and from bytes,
+	// for WriteTo and ReadFrom.
+	ValueCodec ValueCodec
 }
 
 func NewHash() *Hash {
-	rval := &Hash{exponent: 0, buckets: make([]unsafe.Pointer, max_exponent), size: 0, loadFactor: default_load_factor}
+	return NewHashWithOptions(HashOptions{})
+}
+
+func NewHashWithOptions(opts HashOptions) *Hash {
+	rval := &Hash{exponent: 0, maxExponent: max_exponent_32, size: 0, loadFactor: default_load_factor, hashFunc: opts.HashFunc, hashFunc64: opts.HashFunc64, codec: opts.ValueCodec}
+	if rval.hashFunc64 != nil {
+		rval.maxExponent = max_exponent
+		if opts.Seeded {
+			rval.hashFunc64 = RandomSipHash64()
+		}
+	} else if opts.Seeded {
+		rval.hashFunc = RandomSipHash()
+	} else if rval.hashFunc == nil {
+		rval.hashFunc = CRC32Hash
+	}
+	if opts.LoadFactor > 0 {
+		rval.loadFactor = opts.LoadFactor
+	}
+	if opts.Stats {
+		rval.stats = &hashStats{}
+	}
+	if opts.TTL {
+		rval.ttl = true
+		rval.elements = newTTLElement
+	}
+	rval.buckets = make([]unsafe.Pointer, rval.maxExponent+1)
 	b := make([]unsafe.Pointer, 1)
 	rval.buckets[0] = unsafe.Pointer(&b)
+	if opts.Capacity > 0 {
+		rval.minExponent = rval.exponentFor(opts.Capacity)
+		for rval.exponent < rval.minExponent {
+			rval.grow()
+		}
+	}
+	if opts.Presize {
+		rval.presize()
+	}
 	return rval
 }
 
+// exponentFor returns the lowest exponent at which the Hash can hold
+// size entries without growing.
+func (self *Hash) exponentFor(size int) (exponent uint32) {
+	for exponent < self.maxExponent && float64(size) > self.loadFactor*float64(uint64(1)<<exponent) {
+		exponent++
+	}
+	return
+}
+
+// presize creates the sentinel elements of all buckets at the current
+// exponent. It links them directly in split order, and may only be
+// used before the Hash is shared with other goroutines.
+func (self *Hash) presize() {
+	self.linkRange(0, uint64(1)<<self.exponent, nil)
+	self.sentinels = 1 << self.exponent
+}
+
+// HashCode returns the hash code the Hash uses for k, for use with GetHC, PutHC and DeleteHC.
+// It panics if the Hash uses HashFunc64, whose hash codes don't fit.
+func (self *Hash) HashCode(k Key) uint32 {
+	self.check32()
+	return uint32(self.hashCode(k))
+}
+
+// HashCode64 returns the hash code the Hash uses for k, for use with GetHC64, PutHC64 and DeleteHC64.
+func (self *Hash) HashCode64(k Key) uint64 {
+	return self.hashCode(k)
+}
+
+// check32 panics if the Hash uses 64 bit hash codes, for the methods
+// that take or return 32 bit ones.
+func (self *Hash) check32() {
+	if self.hashFunc64 != nil {
+		panic("gotomic: 32 bit hash code used with a Hash that has HashFunc64")
+	}
+}
+
+func (self *Hash) hashCode(k Key) uint64 {
+	if self.hashFunc64 != nil {
+		return self.hashFunc64(k)
+	}
+	return uint64(self.hashFunc(k))
+}
+
 func (self *Hash) Size() int {
 	return int(atomic.LoadInt64(&self.size))
 }
@@ -167,35 +310,48 @@ func (self *Hash) Size() int {
  iteration should be stopped.
 */
 func (self *Hash) Each(i HashIterator) bool {
-	return self.getBucketByHashCode(0).each(func(e entry) bool {
-		return e.real() && i(e.key, e.val())
+	return self.eachEntry(func(e *entry, v unsafe.Pointer) bool {
+		return i(e.key, v)
 	})
 }
 
-/*
- Verify the integrity of the Hash. Used mostly in my own tests but go
- ahead and call it if you fear corruption.
-*/
-// func (self *Hash) Verify() error {
-// 	bucket := self.getBucketByHashCode(0)
-// 	if e := bucket.verify(); e != nil {
-// 		return e
-// 	}
-// 	for bucket != nil {
-// 		e := bucket.value.(*entry)
-// 		if e.real() {
-// 			if ok, index, super, sub := self.isBucket(bucket); ok {
-// 				return fmt.Errorf("%v has %v that should not be a bucket but is bucket %v (%v, %v)", self, e, index, super, sub)
-// 			}
-// 		} else {
-// 			if ok, _, _, _ := self.isBucket(bucket); !ok {
-// 				return fmt.Errorf("%v has %v that should be a bucket but isn't", self, e)
-// 			}
-// 		}
-// 		bucket = bucket.next()
-// 	}
-// 	return nil
-// }
+// eachEntry runs i on each real and not deleted entry along with its
+// value. Unlike element.each it doesn't copy the entries, since their
+// values may be changing.
+func (self *Hash) eachEntry(i func(e *entry, v unsafe.Pointer) bool) bool {
+	return self.eachEntryBetween(0, 0, i)
+}
+
+// eachEntryBetween is eachEntry for the entries with hash keys from
+// start until end, or until the end of the list if end is 0.
+func (self *Hash) eachEntryBetween(start, end uint64, i func(e *entry, v unsafe.Pointer) bool) bool {
+	return self.eachElementBetween(start, end, func(n *element) bool {
+		e := &n.entry
+		if e.real() {
+			if v := e.val(); v != deletedValue && !self.expired(n, v) && i(e, v) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// eachElementBetween runs i on each element, sentinel or not, with hash
+// keys from start until end, or until the end of the list if end is 0.
+func (self *Hash) eachElementBetween(start, end uint64, i func(n *element) bool) bool {
+	for n := self.getBucketByHashCode(reverse64(start)); n != nil; n = n.next() {
+		if n.entry.hashKey < start {
+			continue
+		}
+		if end != 0 && n.entry.hashKey >= end {
+			break
+		}
+		if i(n) {
+			return true
+		}
+	}
+	return false
+}
 
 /*
  ToMap returns a map[Hashable]Thing that is logically identical to the Hash.
@@ -211,13 +367,15 @@ func (self *Hash) ToMap() map[Key]Thing {
 	return rval
 }
 
-func (self *Hash) isBucket(n *element) (isBucket bool, index, superIndex, subIndex uint32) {
-	e := n.entry
-	index = e.hashCode & ((1 << self.exponent) - 1)
+func (self *Hash) isBucket(n *element) (isBucket bool, index, superIndex, subIndex uint64) {
+	e := &n.entry
+	index = e.hashCode & ((1 << atomic.LoadUint32(&self.exponent)) - 1)
 	superIndex, subIndex = self.getBucketIndices(index)
-	subBucket := *(*[]unsafe.Pointer)(self.buckets[superIndex])
-	if subBucket[subIndex] == unsafe.Pointer(n) {
-		isBucket = true
+	if p := atomic.LoadPointer(&self.buckets[superIndex]); p != nil {
+		subBucket := *(*[]unsafe.Pointer)(p)
+		if atomic.LoadPointer(&subBucket[subIndex]) == unsafe.Pointer(n) {
+			isBucket = true
+		}
 	}
 	return
 }
@@ -230,7 +388,7 @@ func (self *Hash) Describe() string {
 	buffer := bytes.NewBufferString(fmt.Sprintf("&Hash{%p size:%v exp:%v maxload:%v}\n", self, self.size, self.exponent, self.loadFactor))
 	element := self.getBucketByIndex(0)
 	for element != nil {
-		e := element.entry
+		e := &element.entry
 		if ok, index, super, sub := self.isBucket(element); ok {
 			fmt.Fprintf(buffer, "%3v:%3v,%3v: %v *\n", index, super, sub, e)
 		} else {
@@ -240,13 +398,49 @@ func (self *Hash) Describe() string {
 	}
 	return string(buffer.Bytes())
 }
+
+// String returns the Hash as JSON if it was created with a ValueCodec,
+// and otherwise the map from ToMap, with its values as pointers. The
+// codec from RegisterValueCodec is not used, since it may not know the
+// type of the values.
 func (self *Hash) String() string {
+	if self.codec != nil {
+		if b, err := self.MarshalJSON(); err == nil {
+			return string(b)
+		}
+	}
 	return fmt.Sprint(self.ToMap())
 }
 
+// search searches for e from bucket like element.search_local, using
+// hh, and records the number of elements it compared e with if the Hash
+// keeps stats.
+func (self *Hash) search(bucket *element, e entry, hh *hit) *hit {
+	hh.element = bucket
+	rval, steps := bucket.search_local_steps(e, hh)
+	if self.stats != nil {
+		self.stats.searched(steps)
+	}
+	return rval
+}
+
+// keyEquality is how a Hash compares the keys of its entries. The zero
+// value compares them with Key.Equals.
+type keyEquality struct {
+	// uint64s compares only the first 8 bytes of the keys, where
+	// MakeKey puts a uint64.
+	uint64s bool
+	// boxed, if not nil, compares the keys of hashes that keep them in
+	// boxes with the values, given the values of the two entries.
+	// Deleted entries have no key, and are never compared.
+	boxed func(cmp, v unsafe.Pointer) bool
+}
+
 type hashHit hit
 
-func (self *hashHit) search(cmp *entry, tmpval *hashHit) (rval *hashHit) {
+// search for cmp among the elements with its hash key, comparing their
+// keys as keys says.
+func (self *hashHit) search(cmp *entry, tmpval *hashHit, keys *keyEquality) (rval *hashHit) {
 	rval = tmpval
 	//	rval = &hashHit{self.left, self.element, self.right}
 	for {
@@ -254,13 +448,21 @@ func (self *hashHit) search(cmp *entry, tmpval *hashHit) (rval *hashHit) {
 			break
 		}
 		rval.right = rval.element.next()
-		e := rval.element.entry
+		e := &rval.element.entry
 		if e.hashKey != cmp.hashKey {
 			rval.right = rval.element
 			rval.element = nil
 			break
 		}
-		if cmp.key.Equals(e.key) {
+		if keys.uint64s {
+			if binary.LittleEndian.Uint64(cmp.key[:8]) == binary.LittleEndian.Uint64(e.key[:8]) {
+				break
+			}
+		} else if keys.boxed == nil {
+			if cmp.key.Equals(e.key) {
+				break
+			}
+		} else if v := e.val(); v != deletedValue && keys.boxed(cmp.value, v) {
 			break
 		}
 		rval.left = rval.element
@@ -274,16 +476,37 @@ func (self *hashHit) search(cmp *entry, tmpval *hashHit) (rval *hashHit) {
 // you already have the hash code and don't want to force gotomic to
 // calculate it again.
 func (self *Hash) GetHC(hashCode uint32, k Key, ld *LocalData) (rval unsafe.Pointer, ok bool) {
+	self.check32()
 	//	fmt.Printf("gotomic: hashcode: %v for key %v ", hashCode, k)
 	//  testEntry := newRealEntryWithHashCode(k, nil, hashCode)
+	ld.te.Set(uint64(hashCode), k)
+	return self.getLocal(ld)
+}
+
+// GetHC64 is GetHC for a Hash with 64 bit hash codes.
+func (self *Hash) GetHC64(hashCode uint64, k Key, ld *LocalData) (rval unsafe.Pointer, ok bool) {
 	ld.te.Set(hashCode, k)
+	return self.getLocal(ld)
+}
+
+// getLocal returns the value of the entry matching ld.te.
+func (self *Hash) getLocal(ld *LocalData) (rval unsafe.Pointer, ok bool) {
+	_, rval, ok = self.getLocalEntry(ld)
+	return
+}
+
+// getLocalEntry returns the element of the entry matching ld.te, if it has a value, and its value.
+func (self *Hash) getLocalEntry(ld *LocalData) (n *element, rval unsafe.Pointer, ok bool) {
 	bucket := self.getBucketByIndexWrapper(ld.te.hashCode, ld.hit)
-	ld.hit.element = bucket
-	hit := (*hashHit)(bucket.search_local(*ld.te, ld.hit))
+	hit := (*hashHit)(self.search(bucket, *ld.te, ld.hit))
 	ld.hh.Set(hit)
-	if hit2 := hit.search(ld.te, ld.hh); hit2.element != nil {
-		rval = hit2.element.entry.val()
-		ok = true
+	if hit2 := hit.search(ld.te, ld.hh, &self.keys); hit2.element != nil {
+		if rval = hit2.element.entry.val(); rval == deletedValue || self.expired(hit2.element, rval) {
+			rval = nil
+		} else {
+			n = hit2.element
+			ok = true
+		}
 	}
 	return
 }
@@ -291,21 +514,28 @@ func (self *Hash) GetHC(hashCode uint32, k Key, ld *LocalData) (rval unsafe.Poin
 // Get returns the value at k and whether it was present in the Hash.
 func (self *Hash) Get(k Key) (unsafe.Pointer, bool) {
 	ld := InitLocalData()
-	return self.GetHC(k.HashCode(), k, ld)
+	return self.GetHC64(self.hashCode(k), k, ld)
 }
 
 // PutIfMissing will insert v under k if k contains expected in the Hash, and return whether it inserted anything.
 func (self *Hash) PutIfPresent(k Key, v unsafe.Pointer, expected Equalable) (rval bool) {
-	newEntry := newRealEntry(k, v)
+	newEntry := newRealEntryWithHashCode(k, v, self.hashCode(k))
 	for {
 		bucket := self.getBucketByHashCode(newEntry.hashCode)
-		hit := (*hashHit)(bucket.search(*newEntry))
+		hit := (*hashHit)(self.search(bucket, *newEntry, ReusableHit()))
 		tmp := &hashHit{hit.left, hit.element, hit.right}
-		if hit2 := hit.search(newEntry, tmp); hit2.element == nil {
+		if hit2 := hit.search(newEntry, tmp, &self.keys); hit2.element == nil {
 			break
 		} else {
-			oldEntry := hit2.element.entry
+			oldEntry := &hit2.element.entry
 			oldValuePtr := atomic.LoadPointer(&oldEntry.value)
+			if oldValuePtr == deletedValue {
+				hit2.element.doRemove()
+				break
+			}
+			if self.expire(hit2.element, oldValuePtr) {
+				break
+			}
 			if expected.Equals(*(*Thing)(oldValuePtr)) {
 				if atomic.CompareAndSwapPointer(&oldEntry.value, oldValuePtr, unsafe.Pointer(newEntry.value)) {
 					rval = true
@@ -319,20 +549,78 @@ func (self *Hash) PutIfPresent(k Key, v unsafe.Pointer, expected Equalable) (rva
 	return
 }
 
+// CompareAndSwap will replace the value under k with new if k contains exactly the pointer old, and return whether it replaced anything.
+// Unlike PutIfPresent it compares pointer identity, and works for any type of value.
+func (self *Hash) CompareAndSwap(k Key, old, new unsafe.Pointer) (rval bool) {
+	testEntry := newRealEntryWithHashCode(k, nil, self.hashCode(k))
+	for {
+		bucket := self.getBucketByHashCode(testEntry.hashCode)
+		hit := (*hashHit)(self.search(bucket, *testEntry, ReusableHit()))
+		tmp := &hashHit{hit.left, hit.element, hit.right}
+		hit2 := hit.search(testEntry, tmp, &self.keys)
+		if hit2.element == nil {
+			break
+		}
+		oldEntry := &hit2.element.entry
+		oldValuePtr := atomic.LoadPointer(&oldEntry.value)
+		if oldValuePtr == deletedValue {
+			hit2.element.doRemove()
+			break
+		}
+		if self.expire(hit2.element, oldValuePtr) {
+			break
+		}
+		if oldValuePtr != old {
+			break
+		}
+		if atomic.CompareAndSwapPointer(&oldEntry.value, oldValuePtr, new) {
+			rval = true
+			break
+		}
+	}
+	return
+}
+
+// Swap will put v under k and return the previous value and whether any value was loaded.
+// It is the same operation as Put, under the name sync.Map uses for it.
+func (self *Hash) Swap(k Key, v unsafe.Pointer) (old unsafe.Pointer, loaded bool) {
+	return self.PutHC64(self.hashCode(k), k, v)
+}
+
 // PutIfMissing will insert v under k if k was missing from the Hash, and return whether it inserted anything.
 func (self *Hash) PutIfMissing(k Key, v unsafe.Pointer) (rval bool) {
-	newEntry := newRealEntry(k, v)
-	alloc := &element{}
+	return self.putIfMissing(newRealEntryWithHashCode(k, v, self.hashCode(k)))
+}
+
+// newElement returns an element for a new real entry.
+func (self *Hash) newElement() *element {
+	if self.elements == nil {
+		return &element{}
+	}
+	return self.elements()
+}
+
+func (self *Hash) putIfMissing(newEntry *entry) (rval bool) {
+	return self.putIfMissingElement(newEntry, self.newElement())
+}
+
+// putIfMissingElement is putIfMissing, linking alloc if it inserts newEntry.
+func (self *Hash) putIfMissingElement(newEntry *entry, alloc *element) (rval bool) {
 	for {
 		bucket := self.getBucketByHashCode(newEntry.hashCode)
-		hit := (*hashHit)(bucket.search(*newEntry))
+		hit := (*hashHit)(self.search(bucket, *newEntry, ReusableHit()))
 		tmp := &hashHit{hit.left, hit.element, hit.right}
-		if hit2 := hit.search(newEntry, tmp); hit2.element == nil {
+		if hit2 := hit.search(newEntry, tmp, &self.keys); hit2.element == nil {
 			if hit2.left.addBefore(*newEntry, alloc, hit2.right) {
 				self.addSize(1)
 				return true
 			}
-		} else {
+			self.stats.inc(stat_add_before_retries)
+			self.stats.inc(stat_put_if_missing_retries)
+		} else if v := atomic.LoadPointer(&hit2.element.entry.value); v == deletedValue {
+			// Help the deletion along and try again.
+			hit2.element.doRemove()
+		} else if !self.expire(hit2.element, v) {
 			break
 		}
 	}
@@ -342,23 +630,58 @@ func (self *Hash) PutIfMissing(k Key, v unsafe.Pointer) (rval bool) {
 // PutHC will put k and v in the Hash using hashCode and return the overwritten value and whether any value was overwritten.
 // Use this when you already have the hash code and don't want to force gotomic to calculate it again.
 func (self *Hash) PutHC(hashCode uint32, k Key, v unsafe.Pointer) (rval unsafe.Pointer, ok bool) {
-	newEntry := newRealEntryWithHashCode(k, v, hashCode)
-	alloc := &element{}
+	self.check32()
+	return self.put(newRealEntryWithHashCode(k, v, uint64(hashCode)))
+}
+
+// PutHC64 is PutHC for a Hash with 64 bit hash codes.
+func (self *Hash) PutHC64(hashCode uint64, k Key, v unsafe.Pointer) (rval unsafe.Pointer, ok bool) {
+	return self.put(newRealEntryWithHashCode(k, v, hashCode))
+}
+
+func (self *Hash) put(newEntry *entry) (rval unsafe.Pointer, ok bool) {
+	return self.putStamped(newEntry, nil)
+}
+
+// putStamped is put, also replacing the expiry time of the entry with
+// stamp, a *ttlStamp or nil.
+func (self *Hash) putStamped(newEntry *entry, stamp unsafe.Pointer) (rval unsafe.Pointer, ok bool) {
+	alloc := self.newElement()
+	if stamp != nil {
+		*expiry(alloc) = stamp
+	}
 	for {
 		bucket := self.getBucketByHashCode(newEntry.hashCode)
-		hit := (*hashHit)(bucket.search(*newEntry))
+		hit := (*hashHit)(self.search(bucket, *newEntry, ReusableHit()))
 		tmp := &hashHit{hit.left, hit.element, hit.right}
-		if hit2 := hit.search(newEntry, tmp); hit2.element == nil {
+		if hit2 := hit.search(newEntry, tmp, &self.keys); hit2.element == nil {
 			if hit2.left.addBefore(*newEntry, alloc, hit2.right) {
 				self.addSize(1)
 				break
 			}
+			self.stats.inc(stat_add_before_retries)
+			self.stats.inc(stat_put_retries)
 		} else {
-			oldEntry := hit2.element.entry
-			rval = oldEntry.val()
-			ok = true
-			atomic.StorePointer(&oldEntry.value, newEntry.value)
-			break
+			oldEntry := &hit2.element.entry
+			oldValuePtr := atomic.LoadPointer(&oldEntry.value)
+			if oldValuePtr == deletedValue {
+				// Help the deletion along and try again.
+				hit2.element.doRemove()
+				continue
+			}
+			expired := self.expired(hit2.element, oldValuePtr)
+			// Stamp the new value before it is visible, so that it is never seen with the expiry time of the old one.
+			if self.ttl && (stamp != nil || atomic.LoadPointer(expiry(hit2.element)) != nil) {
+				atomic.StorePointer(expiry(hit2.element), stamp)
+			}
+			if atomic.CompareAndSwapPointer(&oldEntry.value, oldValuePtr, newEntry.value) {
+				if !expired {
+					rval = oldValuePtr
+					ok = true
+				}
+				break
+			}
+			self.stats.inc(stat_put_retries)
 		}
 	}
 	return
@@ -366,60 +689,237 @@ func (self *Hash) PutHC(hashCode uint32, k Key, v unsafe.Pointer) (rval unsafe.P
 
 // Put k and v in the Hash and return the overwritten value and whether any value was overwritten.
 func (self *Hash) Put(k Key, v unsafe.Pointer) (rval unsafe.Pointer, ok bool) {
-	return self.PutHC(k.HashCode(), k, v)
+	return self.PutHC64(self.hashCode(k), k, v)
+}
+
+// DeleteHC will remove k from the Hash using hashCode and return the removed value and whether any value was removed.
+// Use this when you already have the hash code and don't want to force gotomic to calculate it again.
+func (self *Hash) DeleteHC(hashCode uint32, k Key) (rval unsafe.Pointer, ok bool) {
+	self.check32()
+	return self.delete(newRealEntryWithHashCode(k, nil, uint64(hashCode)))
+}
+
+// DeleteHC64 is DeleteHC for a Hash with 64 bit hash codes.
+func (self *Hash) DeleteHC64(hashCode uint64, k Key) (rval unsafe.Pointer, ok bool) {
+	return self.delete(newRealEntryWithHashCode(k, nil, hashCode))
+}
+
+func (self *Hash) delete(testEntry *entry) (rval unsafe.Pointer, ok bool) {
+	for {
+		bucket := self.getBucketByHashCode(testEntry.hashCode)
+		hit := (*hashHit)(self.search(bucket, *testEntry, ReusableHit()))
+		tmp := &hashHit{hit.left, hit.element, hit.right}
+		hit2 := hit.search(testEntry, tmp, &self.keys)
+		if hit2.element == nil {
+			break
+		}
+		oldEntry := &hit2.element.entry
+		oldValuePtr := atomic.LoadPointer(&oldEntry.value)
+		if oldValuePtr == deletedValue {
+			hit2.element.doRemove()
+			break
+		}
+		if self.expire(hit2.element, oldValuePtr) {
+			break
+		}
+		if atomic.CompareAndSwapPointer(&oldEntry.value, oldValuePtr, deletedValue) {
+			self.remove(testEntry, hit2.element)
+			rval = oldValuePtr
+			ok = true
+			break
+		}
+	}
+	return
+}
+
+// Delete will remove k from the Hash and return the removed value and whether any value was removed.
+func (self *Hash) Delete(k Key) (unsafe.Pointer, bool) {
+	return self.DeleteHC64(self.hashCode(k), k)
+}
+
+// CompareAndDelete will remove k from the Hash if k contains expected, and return whether it removed anything.
+func (self *Hash) CompareAndDelete(k Key, expected unsafe.Pointer) (rval bool) {
+	testEntry := newRealEntryWithHashCode(k, nil, self.hashCode(k))
+	for {
+		bucket := self.getBucketByHashCode(testEntry.hashCode)
+		hit := (*hashHit)(self.search(bucket, *testEntry, ReusableHit()))
+		tmp := &hashHit{hit.left, hit.element, hit.right}
+		hit2 := hit.search(testEntry, tmp, &self.keys)
+		if hit2.element == nil {
+			break
+		}
+		oldEntry := &hit2.element.entry
+		oldValuePtr := atomic.LoadPointer(&oldEntry.value)
+		if oldValuePtr == deletedValue {
+			hit2.element.doRemove()
+			break
+		}
+		if self.expire(hit2.element, oldValuePtr) {
+			break
+		}
+		if oldValuePtr != expected {
+			break
+		}
+		if atomic.CompareAndSwapPointer(&oldEntry.value, oldValuePtr, deletedValue) {
+			self.remove(testEntry, hit2.element)
+			rval = true
+			break
+		}
+	}
+	return
+}
+
+// remove marks the logically deleted element containing e as deleted
+// and searches past it to make sure it is unlinked from the list.
+func (self *Hash) remove(e *entry, element *element) {
+	element.doRemove()
+	self.addSize(-1)
+	bucket := self.getBucketByHashCode(e.hashCode)
+	hit := (*hashHit)(self.search(bucket, *e, ReusableHit()))
+	hit.search(e, &hashHit{hit.left, hit.element, hit.right}, &self.keys)
 }
 
 func (self *Hash) addSize(i int) {
 	atomic.AddInt64(&self.size, int64(i))
-	if atomic.LoadInt64(&self.size) > int64(self.loadFactor*float64(uint32(1)<<self.exponent)) {
+	size := atomic.LoadInt64(&self.size)
+	capacity := self.loadFactor * float64(uint64(1)<<atomic.LoadUint32(&self.exponent))
+	if size > int64(capacity) {
 		self.grow()
+	} else if i < 0 && float64(size) < capacity/shrink_hysteresis {
+		self.shrink()
 	}
 }
 func (self *Hash) grow() {
 	oldExponent := atomic.LoadUint32(&self.exponent)
-	newExponent := oldExponent + 1
+	if oldExponent >= self.maxExponent {
+		return
+	}
 	newBuckets := make([]unsafe.Pointer, 1<<oldExponent)
-	if atomic.CompareAndSwapPointer(&self.buckets[newExponent], nil, unsafe.Pointer(&newBuckets)) {
-		atomic.CompareAndSwapUint32(&self.exponent, oldExponent, newExponent)
+	if atomic.CompareAndSwapPointer(&self.buckets[oldExponent+1], nil, unsafe.Pointer(&newBuckets)) {
+		self.finishGrow(oldExponent, unsafe.Pointer(&newBuckets))
+	}
+}
+
+// finishGrow moves the exponent past oldExponent, after newBuckets were
+// put in the slot above it. If a shrink moved the exponent first, it
+// takes newBuckets out again, since no one else can use or remove them
+// and the table could never grow into that slot otherwise.
+func (self *Hash) finishGrow(oldExponent uint32, newBuckets unsafe.Pointer) {
+	if atomic.CompareAndSwapUint32(&self.exponent, oldExponent, oldExponent+1) {
+		self.stats.inc(stat_grows)
+	} else {
+		atomic.CompareAndSwapPointer(&self.buckets[oldExponent+1], newBuckets, nil)
+	}
+}
+
+// shrink halves the bucket table by decrementing the exponent, dropping
+// the last sub bucket slice and removing the sentinel elements it
+// pointed to from the list.
+//
+// Operations that loaded the old exponent may still use the dropped
+// slice. If they find it gone they start over with the new exponent,
+// and if they find one of the removed sentinels in it (or in a slice
+// that a concurrent grow put in its place) they replace it with a new
+// one. A sentinel they add to the dropped slice after it is emptied
+// stays in the list, to be reused the next time the table grows.
+func (self *Hash) shrink() {
+	oldExponent := atomic.LoadUint32(&self.exponent)
+	if oldExponent <= self.minExponent {
+		return
+	}
+	if !atomic.CompareAndSwapUint32(&self.exponent, oldExponent, oldExponent-1) {
+		return
+	}
+	self.stats.inc(stat_shrinks)
+	oldBuckets := atomic.SwapPointer(&self.buckets[oldExponent], nil)
+	if oldBuckets == nil {
+		return
+	}
+	subBuckets := *(*[]unsafe.Pointer)(oldBuckets)
+	for index := range subBuckets {
+		bucket := (*element)(atomic.LoadPointer(&subBuckets[index]))
+		if bucket == nil {
+			continue
+		}
+		if bucket.doRemove() {
+			atomic.AddInt64(&self.sentinels, -1)
+		}
+		previousBucket := self.getBucketByHashCode(bucket.entry.hashCode)
+		previousBucket.search(bucket.entry)
 	}
 }
 
-func (self *Hash) getPreviousBucketIndex(bucketKey uint32) uint32 {
+// getPreviousBucketIndex returns the index of the bucket before the one
+// with bucketKey in split order, at the current exponent or at the
+// exponent where the bucket first appears, whichever is higher.
+func (self *Hash) getPreviousBucketIndex(bucketKey uint64) uint64 {
 	exp := atomic.LoadUint32(&self.exponent)
-	return reverse(((bucketKey >> (max_exponent - exp)) - 1) << (max_exponent - exp))
+	if level := uint32(max_exponent - bits.TrailingZeros64(bucketKey)); exp < level {
+		exp = level
+	}
+	return reverse64(((bucketKey >> (max_exponent - exp)) - 1) << (max_exponent - exp))
 }
 
-func (self *Hash) getBucketByHashCode(hashCode uint32) *element {
-	x := hashCode & ((1 << self.exponent) - 1)
-	return self.getBucketByIndex(x)
+func (self *Hash) getBucketByHashCode(hashCode uint64) (bucket *element) {
+	for bucket == nil {
+		x := hashCode & ((1 << atomic.LoadUint32(&self.exponent)) - 1)
+		bucket = self.getBucketByIndex(x)
+	}
+	return
 }
 
-func (self *Hash) getBucketIndices(index uint32) (superIndex, subIndex uint32) {
+func (self *Hash) getBucketIndices(index uint64) (superIndex, subIndex uint64) {
 	if index > 0 {
-		superIndex = log2(index)
+		superIndex = log2_64(index)
 		subIndex = index - (1 << superIndex)
 		superIndex++
 	}
 	return
 }
 
-func (self *Hash) getBucketByIndex(index uint32) (bucket *element) {
+// getSubBuckets returns the sub bucket slice containing index, or nil
+// if the table shrunk and it is gone.
+func (self *Hash) getSubBuckets(index uint64) (subBuckets []unsafe.Pointer, subIndex uint64) {
 	superIndex, subIndex := self.getBucketIndices(index)
-	subBuckets := *(*[]unsafe.Pointer)(self.buckets[superIndex])
+	if p := atomic.LoadPointer(&self.buckets[superIndex]); p != nil {
+		subBuckets = *(*[]unsafe.Pointer)(p)
+	}
+	return
+}
+
+// getBucketByIndex returns the sentinel element for index, creating it
+// if necessary, or nil if the table shrunk and index is gone.
+func (self *Hash) getBucketByIndex(index uint64) (bucket *element) {
+	subBuckets, subIndex := self.getSubBuckets(index)
+	if subBuckets == nil {
+		return nil
+	}
 	for {
-		bucket = (*element)(subBuckets[subIndex])
+		bucket = (*element)(atomic.LoadPointer(&subBuckets[subIndex]))
 		if bucket != nil {
-			break
+			if !isDeleted(atomic.LoadPointer(&bucket.Pointer)) {
+				break
+			}
+			// Removed by shrink, replace it.
+			atomic.CompareAndSwapPointer(&subBuckets[subIndex], unsafe.Pointer(bucket), nil)
+			continue
 		}
 		mockEntry := newMockEntry(index)
 		if index == 0 {
 			bucket := &element{Pointer: nil, entry: *mockEntry}
-			atomic.CompareAndSwapPointer(&subBuckets[subIndex], nil, unsafe.Pointer(bucket))
+			if atomic.CompareAndSwapPointer(&subBuckets[subIndex], nil, unsafe.Pointer(bucket)) {
+				atomic.AddInt64(&self.sentinels, 1)
+			}
 		} else {
 			prev := self.getPreviousBucketIndex(mockEntry.hashKey)
 			previousBucket := self.getBucketByIndex(prev)
+			if previousBucket == nil {
+				return nil
+			}
 			if hit := previousBucket.search(*mockEntry); hit.element == nil {
-				hit.left.addBefore(*mockEntry, &element{}, hit.right)
+				if hit.left.addBefore(*mockEntry, &element{}, hit.right) {
+					atomic.AddInt64(&self.sentinels, 1)
+				}
 			} else {
 				atomic.CompareAndSwapPointer(&subBuckets[subIndex], nil, unsafe.Pointer(hit.element))
 			}
@@ -428,27 +928,44 @@ func (self *Hash) getBucketByIndex(index uint32) (bucket *element) {
 	return bucket
 }
 
-func (self *Hash) getBucketByIndexWrapper(hashCode uint32, hh *hit) (bucket *element) {
-	index := hashCode & ((1 << self.exponent) - 1)
-	superIndex, subIndex := self.getBucketIndices(index)
-	subBuckets := *(*[]unsafe.Pointer)(self.buckets[superIndex])
-	for {
-		bucket = (*element)(subBuckets[subIndex])
-		if bucket != nil {
-			break
+func (self *Hash) getBucketByIndexWrapper(hashCode uint64, hh *hit) (bucket *element) {
+	for bucket == nil {
+		index := hashCode & ((1 << atomic.LoadUint32(&self.exponent)) - 1)
+		subBuckets, subIndex := self.getSubBuckets(index)
+		if subBuckets == nil {
+			continue
 		}
-		mockEntry := newMockEntry(index)
-		if index == 0 {
-			bucket := &element{Pointer: nil, entry: *mockEntry}
-			atomic.CompareAndSwapPointer(&subBuckets[subIndex], nil, unsafe.Pointer(bucket))
-		} else {
-			prev := self.getPreviousBucketIndex(mockEntry.hashKey)
-			previousBucket := self.getBucketByIndex(prev)
-			hh.element = previousBucket
-			if hit := previousBucket.search_local(*mockEntry, hh); hit.element == nil {
-				hit.left.addBefore(*mockEntry, &element{}, hit.right)
+		for {
+			bucket = (*element)(atomic.LoadPointer(&subBuckets[subIndex]))
+			if bucket != nil {
+				if !isDeleted(atomic.LoadPointer(&bucket.Pointer)) {
+					break
+				}
+				atomic.CompareAndSwapPointer(&subBuckets[subIndex], unsafe.Pointer(bucket), nil)
+				continue
+			}
+			mockEntry := newMockEntry(index)
+			if index == 0 {
+				bucket := &element{Pointer: nil, entry: *mockEntry}
+				if atomic.CompareAndSwapPointer(&subBuckets[subIndex], nil, unsafe.Pointer(bucket)) {
+					atomic.AddInt64(&self.sentinels, 1)
+				}
 			} else {
-				atomic.CompareAndSwapPointer(&subBuckets[subIndex], nil, unsafe.Pointer(hit.element))
+				prev := self.getPreviousBucketIndex(mockEntry.hashKey)
+				previousBucket := self.getBucketByIndex(prev)
+				if previousBucket == nil {
+					break
+				}
+				hh.element = previousBucket
+				if hit := previousBucket.search_local(*mockEntry, hh); hit.element == nil {
+					if hit.left.addBefore(*mockEntry, &element{}, hit.right) {
+						atomic.AddInt64(&self.sentinels, 1)
+					} else {
+						self.stats.inc(stat_add_before_retries)
+					}
+				} else {
+					atomic.CompareAndSwapPointer(&subBuckets[subIndex], nil, unsafe.Pointer(hit.element))
+				}
 			}
 		}
 	}
@@ -5,8 +5,10 @@ import (
 	"math/rand"
 	"reflect"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 func init() {
@@ -253,3 +255,385 @@ func TestHashEachInterrupt(t *testing.T) {
 	}
 	fmt.Println("...Done TestHashInterrupt")
 }
+
+func TestHashDelete(t *testing.T) {
+	h := NewHash()
+	v := "v"
+	if _, ok := h.Delete(MakeKey(1)); ok {
+		t.Error(h, "should not contain 1")
+	}
+	h.Put(MakeKey(1), unsafe.Pointer(&v))
+	h.Put(MakeKey(2), unsafe.Pointer(&v))
+	if old, ok := h.Delete(MakeKey(1)); !ok || old != unsafe.Pointer(&v) {
+		t.Error(h, "should have contained 1 => v, got", old, ok)
+	}
+	if _, ok := h.Get(MakeKey(1)); ok {
+		t.Error(h, "should not contain 1")
+	}
+	if _, ok := h.Delete(MakeKey(1)); ok {
+		t.Error(h, "should not contain 1")
+	}
+	if h.Size() != 1 {
+		t.Error(h, "should have size 1, had", h.Size())
+	}
+	if !h.PutIfMissing(MakeKey(1), unsafe.Pointer(&v)) {
+		t.Error(h, "should not contain 1")
+	}
+	if _, ok := h.Get(MakeKey(1)); !ok {
+		t.Error(h, "should contain 1")
+	}
+	fmt.Println("...Done TestHashDelete")
+}
+
+func TestHashDeleteConcurrency(t *testing.T) {
+	h := NewHash()
+	n := 10000
+	values := make([]int, n)
+	for i := 0; i < n; i++ {
+		values[i] = i
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+	}
+	var wg sync.WaitGroup
+	var deleted int64
+	var mutex sync.Mutex
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				if j%2 == 0 {
+					if _, ok := h.Delete(MakeKey(uint64(j))); ok {
+						mutex.Lock()
+						deleted++
+						mutex.Unlock()
+					}
+				} else {
+					h.Put(MakeKey(uint64(j)), unsafe.Pointer(&values[j]))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if deleted != int64(n/2) {
+		t.Error("should have deleted", n/2, "keys, deleted", deleted)
+	}
+	if h.Size() != n/2 {
+		t.Error(h.Describe(), "should have size", n/2, "had", h.Size())
+	}
+	count := 0
+	h.Each(func(k Key, v unsafe.Pointer) bool {
+		count++
+		return false
+	})
+	if count != n/2 {
+		t.Error("should have", n/2, "entries, had", count)
+	}
+	fmt.Println("...Done TestHashDeleteConcurrency")
+}
+
+func TestHashCompareAndDelete(t *testing.T) {
+	h := NewHash()
+	v1, v2 := "v1", "v2"
+	if h.CompareAndDelete(MakeKey(1), nil) {
+		t.Error(h, "should not contain 1")
+	}
+	h.Put(MakeKey(1), unsafe.Pointer(&v1))
+	if h.CompareAndDelete(MakeKey(1), unsafe.Pointer(&v2)) {
+		t.Error(h, "should not contain 1 => v2")
+	}
+	if _, ok := h.Get(MakeKey(1)); !ok {
+		t.Error(h, "should contain 1")
+	}
+	if !h.CompareAndDelete(MakeKey(1), unsafe.Pointer(&v1)) {
+		t.Error(h, "should contain 1 => v1")
+	}
+	if _, ok := h.Get(MakeKey(1)); ok {
+		t.Error(h, "should not contain 1")
+	}
+	if h.Size() != 0 {
+		t.Error(h, "should be empty, had size", h.Size())
+	}
+	h.Put(MakeKey(2), nil)
+	if !h.CompareAndDelete(MakeKey(2), nil) {
+		t.Error(h, "should contain 2 => nil")
+	}
+	fmt.Println("...Done TestHashCompareAndDelete")
+}
+
+func TestHashCompareAndSwap(t *testing.T) {
+	h := NewHash()
+	v1, v2, v3 := "v1", "v2", "v3"
+	if h.CompareAndSwap(MakeKey(1), nil, unsafe.Pointer(&v1)) {
+		t.Error(h, "should not contain 1")
+	}
+	if old, loaded := h.Swap(MakeKey(1), unsafe.Pointer(&v1)); loaded || old != nil {
+		t.Error(h, "should not contain 1, got", old, loaded)
+	}
+	if h.CompareAndSwap(MakeKey(1), unsafe.Pointer(&v2), unsafe.Pointer(&v3)) {
+		t.Error(h, "should not contain 1 => v2")
+	}
+	if !h.CompareAndSwap(MakeKey(1), unsafe.Pointer(&v1), unsafe.Pointer(&v2)) {
+		t.Error(h, "should contain 1 => v1")
+	}
+	if v, _ := h.Get(MakeKey(1)); v != unsafe.Pointer(&v2) {
+		t.Error(h, "should contain 1 => v2")
+	}
+	if old, loaded := h.Swap(MakeKey(1), unsafe.Pointer(&v3)); !loaded || old != unsafe.Pointer(&v2) {
+		t.Error(h, "should have contained 1 => v2, got", old, loaded)
+	}
+	h.Delete(MakeKey(1))
+	if h.CompareAndSwap(MakeKey(1), unsafe.Pointer(&v3), unsafe.Pointer(&v1)) {
+		t.Error(h, "should not contain 1")
+	}
+	fmt.Println("...Done TestHashCompareAndSwap")
+}
+
+func TestHashCompareAndSwapConcurrency(t *testing.T) {
+	h := NewHash()
+	n := 1000
+	zero := 0
+	h.Put(MakeKey(1), unsafe.Pointer(&zero))
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				for {
+					old, _ := h.Get(MakeKey(1))
+					next := *(*int)(old) + 1
+					if h.CompareAndSwap(MakeKey(1), old, unsafe.Pointer(&next)) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := h.Get(MakeKey(1)); *(*int)(v) != n*runtime.NumCPU() {
+		t.Error(h, "should have counted to", n*runtime.NumCPU(), "but got", *(*int)(v))
+	}
+	fmt.Println("...Done TestHashCompareAndSwapConcurrency")
+}
+
+func TestHash64(t *testing.T) {
+	if r := reverse64(1); r != 1<<63 {
+		t.Errorf("reverse64(1) should be %x but was %x", uint64(1<<63), r)
+	}
+	if r := reverse64(0x0123456789abcdef); r != 0xf7b3d591e6a2c480 {
+		t.Errorf("reverse64(0x0123456789abcdef) should be %x but was %x", uint64(0xf7b3d591e6a2c480), r)
+	}
+	if l := log2_64(1 << 40); l != 40 {
+		t.Error("log2_64(1 << 40) should be 40 but was", l)
+	}
+	for _, f := range []HashFunc64{MixHash64, SipHash64(1, 2)} {
+		h := NewHashWithOptions(HashOptions{HashFunc64: f})
+		n := 10000
+		values := make([]int, n)
+		for i := 0; i < n; i++ {
+			values[i] = i
+			h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+		}
+		if h.Size() != n {
+			t.Error(h, "should have size", n, "but had", h.Size())
+		}
+		ld := InitLocalData()
+		for i := 0; i < n; i++ {
+			k := MakeKey(uint64(i))
+			if v, ok := h.GetHC64(f(k), k, ld); !ok || *(*int)(v) != i {
+				t.Error(h, "should contain", i)
+			}
+		}
+		for i := 0; i < n; i += 2 {
+			k := MakeKey(uint64(i))
+			if _, ok := h.DeleteHC64(h.HashCode64(k), k); !ok {
+				t.Error(h, "should contain", i)
+			}
+		}
+		if m := h.ToMap(); len(m) != n/2 {
+			t.Error(h, "should have", n/2, "entries but had", len(m))
+		}
+		k := MakeKey(1)
+		for name, f := range map[string]func(){
+			"HashCode": func() { h.HashCode(k) },
+			"GetHC":    func() { h.GetHC(1, k, ld) },
+			"PutHC":    func() { h.PutHC(1, k, nil) },
+			"DeleteHC": func() { h.DeleteHC(1, k) },
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Error(h, "should panic in", name)
+					}
+				}()
+				f()
+			}()
+		}
+	}
+	fmt.Println("...Done TestHash64")
+}
+
+func TestEntrySize(t *testing.T) {
+	// Whatever only some hashes need goes in their elements or value
+	// boxes, to keep the list of every Hash compact.
+	want := 2*unsafe.Sizeof(uint64(0)) + unsafe.Sizeof(Key{}) + unsafe.Sizeof(uintptr(0))
+	if s := unsafe.Sizeof(entry{}); s != want {
+		t.Error("entry should be", want, "bytes but is", s)
+	}
+	fmt.Println("...Done TestEntrySize")
+}
+
+func TestHashShrink(t *testing.T) {
+	h := NewHash()
+	n := 10000
+	values := make([]int, n)
+	for i := 0; i < n; i++ {
+		values[i] = i
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+	}
+	grownExponent := h.exponent
+	for i := 10; i < n; i++ {
+		h.Delete(MakeKey(uint64(i)))
+	}
+	if h.exponent >= grownExponent-5 {
+		t.Error(h, "should have shrunk from exponent", grownExponent, "but has exponent", h.exponent)
+	}
+	elements := 0
+	h.getBucketByIndex(0).each(func(e entry) bool {
+		elements++
+		return false
+	})
+	if elements > 10+1<<h.exponent {
+		t.Error(h.Describe(), "should have at most", 10+1<<h.exponent, "elements but had", elements)
+	}
+	for i := 0; i < 10; i++ {
+		if v, ok := h.Get(MakeKey(uint64(i))); !ok || *(*int)(v) != i {
+			t.Error(h, "should contain", i)
+		}
+	}
+	for i := 0; i < n; i++ {
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+	}
+	if h.exponent != grownExponent {
+		t.Error(h, "should have grown back to exponent", grownExponent, "but has exponent", h.exponent)
+	}
+	if h.Size() != n {
+		t.Error(h, "should have size", n, "but had", h.Size())
+	}
+	fmt.Println("...Done TestHashShrink")
+}
+
+func TestHashGrowRacingShrink(t *testing.T) {
+	h := NewHash()
+	for i := 0; i < 64; i++ {
+		h.Put(MakeKey(uint64(i)), nil)
+	}
+	oldExponent := h.exponent
+	// A grow puts its slice in the directory...
+	newBuckets := make([]unsafe.Pointer, 1<<oldExponent)
+	h.buckets[oldExponent+1] = unsafe.Pointer(&newBuckets)
+	// ...a shrink gets in before it moves the exponent...
+	h.shrink()
+	// ...and then the grow finds the exponent changed.
+	h.finishGrow(oldExponent, unsafe.Pointer(&newBuckets))
+	if h.exponent != oldExponent-1 {
+		t.Error(h, "should have shrunk to exponent", oldExponent-1, "but has exponent", h.exponent)
+	}
+	if h.buckets[oldExponent+1] != nil {
+		t.Error(h, "should have taken the slice of the failed grow out of the directory")
+	}
+	for i := 64; i < 1000; i++ {
+		h.Put(MakeKey(uint64(i)), nil)
+	}
+	if h.exponent <= oldExponent+1 {
+		t.Error(h, "should have grown past exponent", oldExponent+1, "but has exponent", h.exponent)
+	}
+	if err := h.Verify(); err != nil {
+		t.Error(h, "should verify after growing, got", err)
+	}
+	fmt.Println("...Done TestHashGrowRacingShrink")
+}
+
+func TestHashShrinkConcurrency(t *testing.T) {
+	h := NewHash()
+	n := 2000
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			values := make([]int, n)
+			for round := 0; round < 5; round++ {
+				for j := 0; j < n; j++ {
+					values[j] = j
+					h.Put(MakeKey(uint64(offset*n+j)), unsafe.Pointer(&values[j]))
+				}
+				for j := 0; j < n; j++ {
+					k := MakeKey(uint64(offset*n + j))
+					if v, ok := h.Get(k); !ok || *(*int)(v) != j {
+						t.Error("should contain", offset*n+j)
+					}
+					if _, ok := h.Delete(k); !ok {
+						t.Error("should have deleted", offset*n+j)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if h.Size() != 0 {
+		t.Error(h, "should be empty, had size", h.Size())
+	}
+	h.Each(func(k Key, v unsafe.Pointer) bool {
+		t.Error(h, "should be empty, had", k)
+		return false
+	})
+	if err := h.Verify(); err != nil {
+		t.Error(h, "should verify after shrinking, got", err)
+	}
+	fmt.Println("...Done TestHashShrinkConcurrency")
+}
+
+func TestHashWithOptions(t *testing.T) {
+	n := 10000
+	h := NewHashWithOptions(HashOptions{Capacity: n, Presize: true})
+	if h.exponent != 15 {
+		t.Error(h, "should start at exponent 15 but has", h.exponent)
+	}
+	sentinels := 0
+	h.getBucketByIndex(0).each(func(e entry) bool {
+		if e.real() {
+			t.Error(h, "should only contain sentinels, but contained", &e)
+		}
+		sentinels++
+		return false
+	})
+	if sentinels != 1<<15 {
+		t.Error(h, "should have", 1<<15, "sentinels but had", sentinels)
+	}
+	values := make([]int, n)
+	for i := 0; i < n; i++ {
+		values[i] = i
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+	}
+	if h.exponent != 15 {
+		t.Error(h, "should still have exponent 15 but has", h.exponent)
+	}
+	for i := 0; i < n; i++ {
+		if v, ok := h.Get(MakeKey(uint64(i))); !ok || *(*int)(v) != i {
+			t.Error(h, "should contain", i)
+		}
+		h.Delete(MakeKey(uint64(i)))
+	}
+	if h.exponent != 15 {
+		t.Error(h, "should not shrink below exponent 15 but has", h.exponent)
+	}
+	h = NewHashWithOptions(HashOptions{LoadFactor: 4})
+	for i := 0; i < 64; i++ {
+		h.Put(MakeKey(uint64(i)), nil)
+	}
+	if h.exponent != 4 {
+		t.Error(h, "should have exponent 4 with load factor 4 but has", h.exponent)
+	}
+	fmt.Println("...Done TestHashWithOptions")
+}
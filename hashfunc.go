@@ -0,0 +1,86 @@
+package gotomic
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/bits"
+)
+
+// HashFunc calculates the hash code of a Key. Which one performs best
+// depends on the distribution of the keys, see HashOptions.
+type HashFunc func(k Key) uint32
+
+// HashFunc64 calculates the 64 bit hash code of a Key, see
+// HashOptions.HashFunc64.
+type HashFunc64 func(k Key) uint64
+
+const (
+	fnv_offset_32 = 2166136261
+	fnv_prime_32  = 16777619
+	mix_seed_0    = 0xa0761d6478bd642f
+	mix_seed_1    = 0xe7037ed1a0b428db
+)
+
+// CRC32Hash hashes all 16 bytes of k with crc32.ChecksumIEEE. It is the
+// same as Key.HashCode, and the default.
+func CRC32Hash(k Key) uint32 {
+	return crc32.ChecksumIEEE(k[:])
+}
+
+// FNV1aHash hashes all 16 bytes of k with 32 bit FNV-1a.
+func FNV1aHash(k Key) uint32 {
+	var h uint32 = fnv_offset_32
+	for _, b := range k {
+		h ^= uint32(b)
+		h *= fnv_prime_32
+	}
+	return h
+}
+
+// MixHash hashes k by multiplying and folding its two 64 bit halves,
+// in the style of wyhash. It is much faster than CRC32Hash, and spreads
+// sequential keys from MakeKey evenly.
+func MixHash(k Key) uint32 {
+	lo := binary.LittleEndian.Uint64(k[:8])
+	hi := binary.LittleEndian.Uint64(k[8:])
+	return fold(mix(lo^mix_seed_0, hi^mix_seed_1))
+}
+
+// MixHash64 is MixHash without folding the result to 32 bits.
+func MixHash64(k Key) uint64 {
+	lo := binary.LittleEndian.Uint64(k[:8])
+	hi := binary.LittleEndian.Uint64(k[8:])
+	return mix(mix(lo^mix_seed_0, hi^mix_seed_1), mix_seed_1)
+}
+
+// IdentityHash uses the first 4 bytes of k, little endian, as hash code.
+// Use it for keys that already contain a well distributed hash.
+func IdentityHash(k Key) uint32 {
+	return binary.LittleEndian.Uint32(k[:4])
+}
+
+// Uint64MixHash hashes the uint64 in the first 8 bytes of k, as put
+// there by MakeKey, with the murmur3 finalizer. The last 8 bytes are
+// ignored. It is the HashFunc of Uint64Hash.
+func Uint64MixHash(k Key) uint32 {
+	return mixUint64(binary.LittleEndian.Uint64(k[:8]))
+}
+
+func mixUint64(x uint64) uint32 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return uint32(x ^ (x >> 32))
+}
+
+func mix(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+func fold(h uint64) uint32 {
+	h = mix(h, mix_seed_1)
+	return uint32(h ^ (h >> 32))
+}
@@ -0,0 +1,68 @@
+package gotomic
+
+import (
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+var hashFuncs = map[string]HashFunc{
+	"CRC32Hash":    CRC32Hash,
+	"FNV1aHash":    FNV1aHash,
+	"MixHash":      MixHash,
+	"IdentityHash": IdentityHash,
+}
+
+func TestHashFuncs(t *testing.T) {
+	n := 10000
+	values := make([]int, n)
+	for name, f := range hashFuncs {
+		h := NewHashWithOptions(HashOptions{HashFunc: f})
+		for i := 0; i < n; i++ {
+			values[i] = i
+			h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+		}
+		if h.Size() != n {
+			t.Error(name, h, "should have size", n, "but had", h.Size())
+		}
+		ld := InitLocalData()
+		for i := 0; i < n; i++ {
+			k := MakeKey(uint64(i))
+			if v, ok := h.GetHC(f(k), k, ld); !ok || *(*int)(v) != i {
+				t.Error(name, h, "should contain", i)
+			}
+		}
+	}
+	if hc := IdentityHash(MakeKey(0xdeadbeef)); hc != 0xdeadbeef {
+		t.Errorf("IdentityHash should produce %x but produced %x", uint32(0xdeadbeef), hc)
+	}
+	if hc, cmp := CRC32Hash(MakeKey(42)), MakeKey(42).HashCode(); hc != cmp {
+		t.Error("CRC32Hash should produce", cmp, "but produced", hc)
+	}
+	fmt.Println("...Done TestHashFuncs")
+}
+
+func TestMixHashSpread(t *testing.T) {
+	buckets := make([]int, 16)
+	n := 16000
+	for i := 0; i < n; i++ {
+		buckets[MixHash(MakeKey(uint64(i)))&15]++
+	}
+	for i, c := range buckets {
+		if c < n/32 || c > n/8 {
+			t.Error("bucket", i, "should have about", n/16, "keys but had", c)
+		}
+	}
+	fmt.Println("...Done TestMixHashSpread")
+}
+
+func BenchmarkHashFuncs(b *testing.B) {
+	for name, f := range hashFuncs {
+		b.Run(name, func(b *testing.B) {
+			k := MakeKey(12345)
+			for i := 0; i < b.N; i++ {
+				f(k)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package gotomic
+
+import (
+	"context"
+	"iter"
+	"unsafe"
+)
+
+// All returns an iterator over the keys and values of the Hash, for
+// use with range. It walks the list the same way as Each.
+func (self *Hash) All() iter.Seq2[Key, unsafe.Pointer] {
+	return func(yield func(Key, unsafe.Pointer) bool) {
+		self.Each(func(k Key, v unsafe.Pointer) bool {
+			return !yield(k, v)
+		})
+	}
+}
+
+// Keys returns an iterator over the keys of the Hash, for use with range.
+func (self *Hash) Keys() iter.Seq[Key] {
+	return func(yield func(Key) bool) {
+		self.Each(func(k Key, v unsafe.Pointer) bool {
+			return !yield(k)
+		})
+	}
+}
+
+// Values returns an iterator over the values of the Hash, for use with range.
+func (self *Hash) Values() iter.Seq[unsafe.Pointer] {
+	return func(yield func(unsafe.Pointer) bool) {
+		self.Each(func(k Key, v unsafe.Pointer) bool {
+			return !yield(v)
+		})
+	}
+}
+
+// AllContext is All, but stops before the next entry once ctx is done.
+// Check ctx.Err() after the loop to tell a cancelled iteration from a
+// complete one.
+func (self *Hash) AllContext(ctx context.Context) iter.Seq2[Key, unsafe.Pointer] {
+	return func(yield func(Key, unsafe.Pointer) bool) {
+		self.Each(func(k Key, v unsafe.Pointer) bool {
+			return ctx.Err() != nil || !yield(k, v)
+		})
+	}
+}
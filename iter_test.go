@@ -0,0 +1,76 @@
+package gotomic
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+func TestHashAll(t *testing.T) {
+	h := NewHash()
+	n := 100
+	values := make([]int, n)
+	for i := 0; i < n; i++ {
+		values[i] = i
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+	}
+	seen := make(map[Key]unsafe.Pointer)
+	for k, v := range h.All() {
+		seen[k] = v
+	}
+	if len(seen) != n {
+		t.Error("All should produce", n, "entries, but produced", len(seen))
+	}
+	keys := 0
+	for k := range h.Keys() {
+		if _, ok := seen[k]; !ok {
+			t.Error("Keys produced", k, "which All didn't")
+		}
+		keys++
+	}
+	if keys != n {
+		t.Error("Keys should produce", n, "keys, but produced", keys)
+	}
+	sum := 0
+	for v := range h.Values() {
+		sum += *(*int)(v)
+	}
+	if sum != n*(n-1)/2 {
+		t.Error("Values should sum to", n*(n-1)/2, "but summed to", sum)
+	}
+	count := 0
+	for range h.All() {
+		count++
+		if count == 10 {
+			break
+		}
+	}
+	if count != 10 {
+		t.Error("All should stop at break, but produced", count, "entries")
+	}
+	fmt.Println("...Done TestHashAll")
+}
+
+func TestHashAllContext(t *testing.T) {
+	h := NewHash()
+	for i := 0; i < 100; i++ {
+		h.Put(MakeKey(uint64(i)), nil)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	count := 0
+	for range h.AllContext(ctx) {
+		count++
+		if count == 10 {
+			cancel()
+		}
+	}
+	if count != 10 {
+		t.Error("AllContext should stop when cancelled, but produced", count, "entries")
+	}
+	if ctx.Err() == nil {
+		t.Error("context should be cancelled")
+	}
+	fmt.Println("...Done TestHashAllContext")
+}
@@ -9,7 +9,8 @@ import (
 type ListIterator func(e entry) bool
 
 type element struct {
-	// The next element in the list.
+	// The next element in the list. If this pointer has the deleted
+	// flag set it means THIS element, not the next one, is deleted.
 	unsafe.Pointer
 	entry entry
 }
@@ -24,13 +25,51 @@ type Thing interface{}
 
 var list_head = "LIST_HEAD"
 
+// list_tail stands in for a nil next pointer when the last element of
+// the list is deleted, since a nil pointer with the deleted flag set
+// is not a valid pointer.
+var list_tail = &element{}
+
+func isDeleted(p unsafe.Pointer) bool {
+	return uintptr(p)&1 == 1
+}
+func normal(p unsafe.Pointer) unsafe.Pointer {
+	p = unsafe.Pointer(uintptr(p) &^ 1)
+	if p == unsafe.Pointer(list_tail) {
+		return nil
+	}
+	return p
+}
+func deleted(p unsafe.Pointer) unsafe.Pointer {
+	if p == nil {
+		p = unsafe.Pointer(list_tail)
+	}
+	return unsafe.Pointer(uintptr(p) + 1)
+}
+
+// next returns the first element after self that is not deleted.
+// Deleted elements passed on the way are unlinked from the list,
+// unless self is deleted as well, in which case they are just skipped
+// and left for whoever unlinks self.
 func (self *element) next() *element {
 	next := atomic.LoadPointer(&self.Pointer)
-	if next == nil {
-		return nil
+	for {
+		nextElement := (*element)(normal(next))
+		if nextElement == nil {
+			return nil
+		}
+		nextNext := atomic.LoadPointer(&nextElement.Pointer)
+		if !isDeleted(nextNext) {
+			return nextElement
+		}
+		if isDeleted(next) {
+			next = nextNext
+		} else if atomic.CompareAndSwapPointer(&self.Pointer, next, normal(nextNext)) {
+			next = normal(nextNext)
+		} else {
+			next = atomic.LoadPointer(&self.Pointer)
+		}
 	}
-	nextElement := (*element)(next)
-	return nextElement
 }
 
 func (self *element) each(i ListIterator) bool {
@@ -68,6 +107,8 @@ func (self *element) add(e entry) (rval bool) {
 	return
 }
 
+// addBefore will fail if self is deleted, since the deleted flag makes
+// its next pointer differ from before.
 func (self *element) addBefore(e entry, allocatedElement, before *element) bool {
 	if self.next() != before {
 		return false
@@ -128,13 +169,21 @@ func (self *element) search(e entry) *hit {
 // searching), the elementRef and element for the match (if a match)
 // and the last elementRef and element after the match (if no match,
 // the first elementRef and element, or nil/nil if at the end of the
-// list).
+// list). Deleted elements on the way are unlinked by next.
 func (self *element) search_local(e entry, hh *hit) (rval *hit) {
+	rval, _ = self.search_local_steps(e, hh)
+	return
+}
+
+// search_local_steps is search_local, also returning the number of
+// elements it compared e with.
+func (self *element) search_local_steps(e entry, hh *hit) (rval *hit, steps int) {
 	rval = hh
 	for {
 		if rval.element == nil {
 			return
 		}
+		steps++
 		rval.right = rval.element.next()
 		var cmp int = e.Compare(&(rval.element.entry))
 		if cmp < 0 {
@@ -148,13 +197,23 @@ func (self *element) search_local(e entry, hh *hit) (rval *hit) {
 		rval.element = rval.left.next()
 		rval.right = nil
 	}
-	panic(fmt.Sprint("Unable to search for ", e, " in ", self))
 }
 
 func ReusableHit() *hit {
 	return &hit{nil, nil, nil}
 }
 
+// doRemove marks self as deleted by setting the deleted flag of its
+// next pointer, and returns whether this call was the one to set it.
+// The element is physically unlinked by the next traversal passing it.
 func (self *element) doRemove() bool {
-	return false
+	for {
+		next := atomic.LoadPointer(&self.Pointer)
+		if isDeleted(next) {
+			return false
+		}
+		if atomic.CompareAndSwapPointer(&self.Pointer, next, deleted(next)) {
+			return true
+		}
+	}
 }
@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 type c int
@@ -307,3 +308,33 @@ func TestConcInjectAndSearch(t *testing.T) {
 		}
 	}
 }
+
+func TestDoRemove(t *testing.T) {
+	nr := new(element)
+	nr.add(*newMockEntry(3))
+	nr.add(*newMockEntry(2))
+	nr.add(*newMockEntry(1))
+	first := nr.next()
+	if !first.doRemove() {
+		t.Error(first, "should be removable")
+	}
+	if first.doRemove() {
+		t.Error(first, "should already be removed")
+	}
+	if nr.next().entry.hashCode != 2 {
+		t.Error(nr, "should skip the removed element, got", nr.next())
+	}
+	if nr.Pointer != unsafe.Pointer(nr.next()) {
+		t.Error(nr, "should have unlinked the removed element")
+	}
+	last := nr.next().next()
+	if !last.doRemove() {
+		t.Error(last, "should be removable")
+	}
+	if nr.next().next() != nil {
+		t.Error(nr, "should end after the second element")
+	}
+	if nr.next().addBefore(*newMockEntry(4), &element{}, last) {
+		t.Error(nr, "should not add before a removed element that was unlinked")
+	}
+}
@@ -0,0 +1,71 @@
+package gotomic
+
+import (
+	"errors"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ErrLoaderPanicked is returned to the callers waiting for a GetOrLoad
+// loader that panicked.
+var ErrLoaderPanicked = errors.New("gotomic: GetOrLoad loader panicked")
+
+type loadCall struct {
+	done  chan struct{}
+	value unsafe.Pointer
+	err   error
+}
+
+func (self *Hash) getLoads() *Hash {
+	if p := atomic.LoadPointer(&self.loads); p != nil {
+		return (*Hash)(p)
+	}
+	loads := NewHashWithOptions(HashOptions{HashFunc: self.hashFunc, HashFunc64: self.hashFunc64})
+	atomic.CompareAndSwapPointer(&self.loads, nil, unsafe.Pointer(loads))
+	return (*Hash)(atomic.LoadPointer(&self.loads))
+}
+
+// GetOrLoad returns the value at k, calling loader to produce and insert
+// it if k is missing.
+//
+// At most one loader runs per key at a time. Calls for a key that is
+// already being loaded wait for that loader, and get its value or error.
+// Errors are not stored, so the next call after a failed load tries
+// again.
+//
+// The loads in flight are kept as placeholders in a separate Hash, so
+// that the other operations never have to tell them from real values.
+func (self *Hash) GetOrLoad(k Key, loader func() (unsafe.Pointer, error)) (unsafe.Pointer, error) {
+	if v, ok := self.Get(k); ok {
+		return v, nil
+	}
+	loads := self.getLoads()
+	call := &loadCall{done: make(chan struct{})}
+	for !loads.PutIfMissing(k, unsafe.Pointer(call)) {
+		if p, ok := loads.Get(k); ok {
+			other := (*loadCall)(p)
+			<-other.done
+			return other.value, other.err
+		}
+	}
+	panicked := true
+	defer func() {
+		if panicked {
+			call.err = ErrLoaderPanicked
+		}
+		loads.CompareAndDelete(k, unsafe.Pointer(call))
+		close(call.done)
+	}()
+	// k may have been loaded by the call that was in flight when we looked.
+	if v, ok := self.Get(k); ok {
+		call.value = v
+	} else if v, err := loader(); err != nil {
+		call.err = err
+	} else {
+		call.value, _ = self.ComputeIfAbsent(k, func() unsafe.Pointer {
+			return v
+		})
+	}
+	panicked = false
+	return call.value, call.err
+}
@@ -0,0 +1,81 @@
+package gotomic
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestHashGetOrLoad(t *testing.T) {
+	h := NewHash()
+	var calls int32
+	release := make(chan struct{})
+	value := "loaded"
+	loader := func() (unsafe.Pointer, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return unsafe.Pointer(&value), nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := h.GetOrLoad(MakeKey(1), loader); err != nil || v != unsafe.Pointer(&value) {
+				t.Error(h, "should load 1 => loaded, got", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Error("loader should be called once, but was called", calls, "times")
+	}
+	if v, ok := h.Get(MakeKey(1)); !ok || v != unsafe.Pointer(&value) {
+		t.Error(h, "should contain 1 => loaded")
+	}
+	if h.Size() != 1 {
+		t.Error(h, "should have size 1 but had", h.Size())
+	}
+	fmt.Println("...Done TestHashGetOrLoad")
+}
+
+func TestHashGetOrLoadError(t *testing.T) {
+	h := NewHash()
+	failure := errors.New("failure")
+	if _, err := h.GetOrLoad(MakeKey(1), func() (unsafe.Pointer, error) {
+		return nil, failure
+	}); err != failure {
+		t.Error("GetOrLoad should return the loader error, got", err)
+	}
+	if _, ok := h.Get(MakeKey(1)); ok {
+		t.Error(h, "should not contain 1 after a failed load")
+	}
+	value := "loaded"
+	if v, err := h.GetOrLoad(MakeKey(1), func() (unsafe.Pointer, error) {
+		return unsafe.Pointer(&value), nil
+	}); err != nil || v != unsafe.Pointer(&value) {
+		t.Error("GetOrLoad should retry after a failed load, got", v, err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("GetOrLoad should pass on the loader panic")
+			}
+		}()
+		h.GetOrLoad(MakeKey(2), func() (unsafe.Pointer, error) {
+			panic("loader")
+		})
+	}()
+	if _, err := h.GetOrLoad(MakeKey(2), func() (unsafe.Pointer, error) {
+		return nil, nil
+	}); err != nil {
+		t.Error("GetOrLoad should work after a loader panic, got", err)
+	}
+	fmt.Println("...Done TestHashGetOrLoadError")
+}
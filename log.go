@@ -24,3 +24,10 @@ func log2(v uint32) uint32 {
 	}
 	return r
 }
+
+func log2_64(v uint64) uint64 {
+	if tt := v >> 32; tt != 0 {
+		return 32 + uint64(log2(uint32(tt)))
+	}
+	return uint64(log2(uint32(v)))
+}
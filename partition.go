@@ -0,0 +1,97 @@
+package gotomic
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Partition is one of the disjoint ranges of a Hash returned by
+// Partitions. Each Partition can be iterated in its own goroutine.
+type Partition struct {
+	hash  *Hash
+	start uint64
+	// end is 0 for the last Partition.
+	end uint64
+}
+
+// Partitions divides the split order of the Hash into n ranges of the
+// same size, which together cover all entries exactly once. Since the
+// split order doesn't change when the bucket table grows or shrinks,
+// the Partitions stay valid however the Hash changes.
+func (self *Hash) Partitions(n int) []*Partition {
+	if n < 1 {
+		n = 1
+	}
+	rval := make([]*Partition, n)
+	step := ^uint64(0) / uint64(n)
+	for i := range rval {
+		rval[i] = &Partition{hash: self, start: uint64(i) * step}
+		if i > 0 {
+			rval[i-1].end = rval[i].start
+		}
+	}
+	return rval
+}
+
+// Each will run i on each key and value in the Partition, and return true if the iteration was interrupted.
+func (self *Partition) Each(i HashIterator) bool {
+	return self.hash.eachEntryBetween(self.start, self.end, func(e *entry, v unsafe.Pointer) bool {
+		return i(e.key, v)
+	})
+}
+
+// ParallelEach will run i on each key and value, using one goroutine for
+// each of n Partitions, so i must be safe to call concurrently.
+//
+// It returns true if the iteration was interrupted, in which case the
+// other goroutines stop as soon as they notice.
+func (self *Hash) ParallelEach(n int, i HashIterator) bool {
+	var interrupted int32
+	var wg sync.WaitGroup
+	for _, partition := range self.Partitions(n) {
+		wg.Add(1)
+		go func(partition *Partition) {
+			defer wg.Done()
+			partition.Each(func(k Key, v unsafe.Pointer) bool {
+				if atomic.LoadInt32(&interrupted) == 1 {
+					return true
+				}
+				if i(k, v) {
+					atomic.StoreInt32(&interrupted, 1)
+					return true
+				}
+				return false
+			})
+		}(partition)
+	}
+	wg.Wait()
+	return interrupted == 1
+}
+
+// ParallelReduce reduces each of n Partitions of h with reduce, in one
+// goroutine each and starting from initial, and then merges the results
+// in split order. initial must be a neutral element for merge.
+func ParallelReduce[T any](h *Hash, n int, initial T, reduce func(acc T, k Key, v unsafe.Pointer) T, merge func(a, b T) T) T {
+	partitions := h.Partitions(n)
+	results := make([]T, len(partitions))
+	var wg sync.WaitGroup
+	for index, partition := range partitions {
+		wg.Add(1)
+		go func(index int, partition *Partition) {
+			defer wg.Done()
+			acc := initial
+			partition.Each(func(k Key, v unsafe.Pointer) bool {
+				acc = reduce(acc, k, v)
+				return false
+			})
+			results[index] = acc
+		}(index, partition)
+	}
+	wg.Wait()
+	rval := initial
+	for _, result := range results {
+		rval = merge(rval, result)
+	}
+	return rval
+}
@@ -0,0 +1,78 @@
+package gotomic
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestHashPartitions(t *testing.T) {
+	h := NewHash()
+	n := 10000
+	values := make([]int, n)
+	for i := 0; i < n; i++ {
+		values[i] = i
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+	}
+	for _, parts := range []int{1, 3, 8} {
+		seen := make(map[Key]int)
+		for _, partition := range h.Partitions(parts) {
+			count := 0
+			partition.Each(func(k Key, v unsafe.Pointer) bool {
+				seen[k]++
+				count++
+				return false
+			})
+			if parts > 1 && (count < n/parts/2 || count > n/parts*2) {
+				t.Error("partition should have about", n/parts, "entries but had", count)
+			}
+		}
+		if len(seen) != n {
+			t.Error("partitions should cover", n, "keys, but covered", len(seen))
+		}
+		for k, c := range seen {
+			if c != 1 {
+				t.Error("partitions should contain", k, "once, but contained it", c, "times")
+			}
+		}
+	}
+	fmt.Println("...Done TestHashPartitions")
+}
+
+func TestHashParallelEach(t *testing.T) {
+	h := NewHash()
+	n := 10000
+	values := make([]int, n)
+	for i := 0; i < n; i++ {
+		values[i] = i
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+	}
+	var lock sync.Mutex
+	seen := make(map[Key]bool)
+	if h.ParallelEach(4, func(k Key, v unsafe.Pointer) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		seen[k] = true
+		return false
+	}) {
+		t.Error("ParallelEach should not be interrupted")
+	}
+	if len(seen) != n {
+		t.Error("ParallelEach should see", n, "keys, but saw", len(seen))
+	}
+	if !h.ParallelEach(4, func(k Key, v unsafe.Pointer) bool {
+		return true
+	}) {
+		t.Error("ParallelEach should be interrupted")
+	}
+	sum := ParallelReduce(h, 4, 0, func(acc int, k Key, v unsafe.Pointer) int {
+		return acc + *(*int)(v)
+	}, func(a, b int) int {
+		return a + b
+	})
+	if sum != n*(n-1)/2 {
+		t.Error("ParallelReduce should sum to", n*(n-1)/2, "but got", sum)
+	}
+	fmt.Println("...Done TestHashParallelEach")
+}
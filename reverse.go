@@ -16,3 +16,7 @@ func reverse(v uint32) uint32 {
 	v = (v >> 16) | (v << 16)
 	return v
 }
+
+func reverse64(v uint64) uint64 {
+	return uint64(reverse(uint32(v)))<<32 | uint64(reverse(uint32(v>>32)))
+}
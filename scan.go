@@ -0,0 +1,41 @@
+package gotomic
+
+import (
+	"unsafe"
+)
+
+// Scan returns up to about count keys and values, starting at cursor,
+// and the cursor to pass to the next call. Start with cursor 0, and
+// stop when the returned cursor is 0 again.
+//
+// The cursor is a position in the split order of the Hash, which
+// doesn't change when the bucket table grows or shrinks. So a full scan
+// returns every key that was present during the whole scan, no matter
+// what happened to the Hash between calls. Keys added or removed during
+// the scan may or may not be returned. Since entries with the same hash
+// key are never split between calls, a call may return more than count
+// entries.
+func (self *Hash) Scan(cursor uint64, count int) (keys []Key, values []unsafe.Pointer, nextCursor uint64) {
+	if count < 1 {
+		count = 1
+	}
+	n := self.getBucketByHashCode(reverse64(cursor))
+	for ; n != nil; n = n.next() {
+		e := &n.entry
+		if e.hashKey < cursor {
+			continue
+		}
+		if len(keys) >= count && e.hashKey != nextCursor-1 {
+			return
+		}
+		if !e.real() {
+			continue
+		}
+		if v := e.val(); v != deletedValue && !self.expired(n, v) {
+			keys = append(keys, e.key)
+			values = append(values, v)
+			nextCursor = e.hashKey + 1
+		}
+	}
+	return keys, values, 0
+}
@@ -0,0 +1,60 @@
+package gotomic
+
+import (
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+func TestHashScan(t *testing.T) {
+	h := NewHash()
+	n := 1000
+	values := make([]int, 2*n)
+	for i := 0; i < n; i++ {
+		values[i] = i
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+	}
+	seen := make(map[Key]int)
+	var cursor uint64
+	pages := 0
+	for {
+		keys, vals, next := h.Scan(cursor, 10)
+		if len(keys) != len(vals) {
+			t.Error("Scan should return as many keys as values, got", len(keys), len(vals))
+		}
+		for i, k := range keys {
+			seen[k]++
+			if *(*int)(vals[i]) != int(k[0])|int(k[1])<<8 {
+				t.Error(h, "should map", k, "to its own number")
+			}
+		}
+		pages++
+		// Resize the table between pages.
+		if pages == 10 {
+			for i := n; i < 2*n; i++ {
+				values[i] = i
+				h.Put(MakeKey(uint64(i)), unsafe.Pointer(&values[i]))
+			}
+		} else if pages == 50 {
+			for i := n; i < 2*n; i++ {
+				h.Delete(MakeKey(uint64(i)))
+			}
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	for i := 0; i < n; i++ {
+		if seen[MakeKey(uint64(i))] != 1 {
+			t.Error("Scan should return", i, "once, but returned it", seen[MakeKey(uint64(i))], "times")
+		}
+	}
+	if pages < n/10 {
+		t.Error("Scan should need at least", n/10, "pages, but needed", pages)
+	}
+	if keys, _, next := NewHash().Scan(0, 10); len(keys) != 0 || next != 0 {
+		t.Error("Scan of an empty Hash should return nothing, got", keys, next)
+	}
+	fmt.Println("...Done TestHashScan")
+}
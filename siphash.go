@@ -0,0 +1,84 @@
+package gotomic
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math/bits"
+)
+
+// SipHash returns a HashFunc running SipHash-2-4 keyed with k0 and k1
+// over all 16 bytes of the key. As long as k0 and k1 are secret, nobody
+// can precompute keys that collide in the Hash, which protects tables
+// with client supplied keys from hash flooding.
+func SipHash(k0, k1 uint64) HashFunc {
+	return func(k Key) uint32 {
+		h := sipHash24(k0, k1, k)
+		return uint32(h ^ (h >> 32))
+	}
+}
+
+// SipHash64 is SipHash without folding the result to 32 bits.
+func SipHash64(k0, k1 uint64) HashFunc64 {
+	return func(k Key) uint64 {
+		return sipHash24(k0, k1, k)
+	}
+}
+
+// RandomSipHash returns a SipHash with a key from crypto/rand.
+func RandomSipHash() HashFunc {
+	return SipHash(randomSipKey())
+}
+
+// RandomSipHash64 returns a SipHash64 with a key from crypto/rand.
+func RandomSipHash64() HashFunc64 {
+	return SipHash64(randomSipKey())
+}
+
+func randomSipKey() (k0, k1 uint64) {
+	var seed [16]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		panic(err)
+	}
+	return binary.LittleEndian.Uint64(seed[:8]), binary.LittleEndian.Uint64(seed[8:])
+}
+
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = bits.RotateLeft64(v1, 13)
+	v1 ^= v0
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v3
+	v3 = bits.RotateLeft64(v3, 16)
+	v3 ^= v2
+	v0 += v3
+	v3 = bits.RotateLeft64(v3, 21)
+	v3 ^= v0
+	v2 += v1
+	v1 = bits.RotateLeft64(v1, 17)
+	v1 ^= v2
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
+}
+
+func sipHash24(k0, k1 uint64, k Key) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+	blocks := [3]uint64{
+		binary.LittleEndian.Uint64(k[:8]),
+		binary.LittleEndian.Uint64(k[8:]),
+		uint64(len(k)) << 56,
+	}
+	for _, m := range blocks {
+		v3 ^= m
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0 ^= m
+	}
+	v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	}
+	return v0 ^ v1 ^ v2 ^ v3
+}
@@ -0,0 +1,41 @@
+package gotomic
+
+import (
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+func TestSipHash(t *testing.T) {
+	var k Key
+	for i := range k {
+		k[i] = byte(i)
+	}
+	if h := sipHash24(0x0706050403020100, 0x0f0e0d0c0b0a0908, k); h != 0x3f2acc7f57c29bdb {
+		t.Errorf("sipHash24 should produce %x but produced %x", uint64(0x3f2acc7f57c29bdb), h)
+	}
+	if SipHash(1, 2)(k) == SipHash(2, 1)(k) {
+		t.Error("SipHash should depend on its key")
+	}
+	fmt.Println("...Done TestSipHash")
+}
+
+func TestHashSeeded(t *testing.T) {
+	h1 := NewHashWithOptions(HashOptions{Seeded: true})
+	h2 := NewHashWithOptions(HashOptions{Seeded: true})
+	same := 0
+	for i := 0; i < 100; i++ {
+		if h1.HashCode(MakeKey(uint64(i))) == h2.HashCode(MakeKey(uint64(i))) {
+			same++
+		}
+	}
+	if same > 1 {
+		t.Error("differently seeded hashes should produce different hash codes, but", same, "were the same")
+	}
+	v := "v"
+	h1.Put(MakeKey(1), unsafe.Pointer(&v))
+	if p, ok := h1.Get(MakeKey(1)); !ok || p != unsafe.Pointer(&v) {
+		t.Error(h1, "should contain 1 => v")
+	}
+	fmt.Println("...Done TestHashSeeded")
+}
@@ -0,0 +1,220 @@
+package gotomic
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"runtime"
+	"unsafe"
+)
+
+// ErrNoValueCodec is returned by WriteTo and ReadFrom for a Hash created
+// without HashOptions.ValueCodec, when no ValueCodec is registered.
+var ErrNoValueCodec = errors.New("gotomic: the Hash has no ValueCodec")
+
+// ErrSnapshotFormat is returned when reading something that is not a
+// snapshot written by WriteTo, or one of an unknown version.
+var ErrSnapshotFormat = errors.New("gotomic: not a known snapshot format")
+
+// ErrSnapshotChecksum is returned when reading a snapshot that was
+// corrupted.
+var ErrSnapshotChecksum = errors.New("gotomic: snapshot checksum mismatch")
+
+// A snapshot is snapshot_magic and snapshot_version, followed by one
+// record per entry: snapshot_entry, the 16 bytes of the key, and the
+// uvarint length and bytes of the encoded value. After the entries
+// comes snapshot_end, the uvarint number of entries, and the
+// little-endian CRC-32C of everything before it.
+const (
+	snapshot_magic     = "GTMC"
+	snapshot_version   = 1
+	snapshot_entry     = 1
+	snapshot_end       = 0
+	snapshot_max_value = 1 << 30
+)
+
+var snapshot_table = crc32.MakeTable(crc32.Castagnoli)
+
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	n   int64
+	err error
+}
+
+func (self *snapshotWriter) Write(b []byte) (int, error) {
+	if self.err != nil {
+		return 0, self.err
+	}
+	self.crc.Write(b)
+	n, err := self.w.Write(b)
+	self.n += int64(n)
+	self.err = err
+	return n, err
+}
+
+func (self *snapshotWriter) writeUvarint(i uint64) {
+	self.Write(binary.AppendUvarint(nil, i))
+}
+
+// WriteTo writes a snapshot of the Hash to w, with the values encoded
+// by the ValueCodec of the Hash, and returns the number of bytes
+// written.
+//
+// The Hash can be changed while it is written. The snapshot then
+// contains all entries that were there during the whole WriteTo, and
+// any subset of the others.
+func (self *Hash) WriteTo(w io.Writer) (n int64, err error) {
+	codec := self.valueCodec()
+	if codec == nil {
+		return 0, ErrNoValueCodec
+	}
+	sw := &snapshotWriter{w: bufio.NewWriter(w), crc: crc32.New(snapshot_table)}
+	sw.Write([]byte{snapshot_magic[0], snapshot_magic[1], snapshot_magic[2], snapshot_magic[3], snapshot_version})
+	var count uint64
+	self.eachEntry(func(e *entry, v unsafe.Pointer) bool {
+		var b []byte
+		if b, err = codec.EncodeValue(v); err != nil {
+			return true
+		}
+		sw.Write([]byte{snapshot_entry})
+		sw.Write(e.key[:])
+		sw.writeUvarint(uint64(len(b)))
+		sw.Write(b)
+		count++
+		return sw.err != nil
+	})
+	if err != nil {
+		return sw.n, err
+	}
+	sw.Write([]byte{snapshot_end})
+	sw.writeUvarint(count)
+	sw.Write(binary.LittleEndian.AppendUint32(nil, sw.crc.Sum32()))
+	if sw.err != nil {
+		return sw.n, sw.err
+	}
+	return sw.n, sw.w.Flush()
+}
+
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	n   int64
+}
+
+func (self *snapshotReader) Read(b []byte) (int, error) {
+	n, err := self.r.Read(b)
+	self.crc.Write(b[:n])
+	self.n += int64(n)
+	return n, err
+}
+
+func (self *snapshotReader) ReadByte() (byte, error) {
+	b, err := self.r.ReadByte()
+	if err == nil {
+		self.crc.Write([]byte{b})
+		self.n++
+	}
+	return b, err
+}
+
+// ReadFrom reads a snapshot written by WriteTo from r into the Hash,
+// with the values decoded by the ValueCodec of the Hash, and returns
+// the number of bytes read. It may read beyond the end of the snapshot.
+//
+// Nothing is added to the Hash unless the whole snapshot is read
+// successfully. The entries are then added with BulkLoad if the Hash is
+// empty, which is only safe if no other goroutine uses it meanwhile,
+// and with Put otherwise.
+func (self *Hash) ReadFrom(r io.Reader) (n int64, err error) {
+	self.initIfZero()
+	codec := self.valueCodec()
+	if codec == nil {
+		return 0, ErrNoValueCodec
+	}
+	sr := &snapshotReader{r: bufio.NewReader(r), crc: crc32.New(snapshot_table)}
+	var keys []Key
+	var values [][]byte
+	if keys, values, err = readSnapshot(sr); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return sr.n, err
+	}
+	// Only decode the values once the checksum says they are intact.
+	pairs := make([]bulkPair, len(keys))
+	for i, k := range keys {
+		pairs[i].key = k
+		if pairs[i].value, err = codec.DecodeValue(values[i]); err != nil {
+			return sr.n, err
+		}
+	}
+	self.bulkLoad(pairs, runtime.NumCPU())
+	return sr.n, nil
+}
+
+// readSnapshot returns the keys and encoded values in the snapshot.
+func readSnapshot(sr *snapshotReader) (keys []Key, values [][]byte, err error) {
+	header := make([]byte, len(snapshot_magic)+1)
+	if _, err = io.ReadFull(sr, header); err != nil {
+		return
+	}
+	if string(header[:len(snapshot_magic)]) != snapshot_magic || header[len(snapshot_magic)] != snapshot_version {
+		return nil, nil, ErrSnapshotFormat
+	}
+	for {
+		var tag byte
+		if tag, err = sr.ReadByte(); err != nil {
+			return
+		}
+		if tag == snapshot_end {
+			break
+		}
+		if tag != snapshot_entry {
+			return nil, nil, ErrSnapshotFormat
+		}
+		var k Key
+		if _, err = io.ReadFull(sr, k[:]); err != nil {
+			return
+		}
+		var length uint64
+		if length, err = binary.ReadUvarint(sr); err != nil {
+			return
+		}
+		if length > snapshot_max_value {
+			return nil, nil, ErrSnapshotFormat
+		}
+		b := make([]byte, length)
+		if _, err = io.ReadFull(sr, b); err != nil {
+			return
+		}
+		keys = append(keys, k)
+		values = append(values, b)
+	}
+	var count uint64
+	if count, err = binary.ReadUvarint(sr); err != nil {
+		return
+	}
+	sum := sr.crc.Sum32()
+	checksum := make([]byte, 4)
+	if _, err = io.ReadFull(sr, checksum); err != nil {
+		return
+	}
+	if binary.LittleEndian.Uint32(checksum) != sum || count != uint64(len(keys)) {
+		return nil, nil, ErrSnapshotChecksum
+	}
+	return
+}
+
+// ReadHashFrom returns a new Hash with the contents of the snapshot
+// written by WriteTo in r, with the values decoded by codec.
+func ReadHashFrom(r io.Reader, codec ValueCodec) (*Hash, error) {
+	rval := NewHashWithOptions(HashOptions{ValueCodec: codec})
+	if _, err := rval.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return rval, nil
+}
@@ -0,0 +1,94 @@
+package gotomic
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestHashSnapshot(t *testing.T) {
+	h := NewHashWithOptions(HashOptions{ValueCodec: GobValueCodec[string]()})
+	for i := 0; i < 1000; i++ {
+		s := fmt.Sprint("value ", i)
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&s))
+	}
+	buf := new(bytes.Buffer)
+	if n, err := h.WriteTo(buf); err != nil || n != int64(buf.Len()) {
+		t.Error(h, "should write a snapshot, got", n, err)
+	}
+	snapshot := buf.Bytes()
+	h2, err := ReadHashFrom(bytes.NewReader(snapshot), GobValueCodec[string]())
+	if err != nil {
+		t.Fatal("should read the snapshot, got", err)
+	}
+	if err := h2.Verify(); err != nil {
+		t.Error("should read a valid Hash, got", err)
+	}
+	if h2.Size() != 1000 {
+		t.Error(h2, "should have size 1000 but had", h2.Size())
+	}
+	for i := 0; i < 1000; i++ {
+		if v, ok := h2.Get(MakeKey(uint64(i))); !ok || *(*string)(v) != fmt.Sprint("value ", i) {
+			t.Error(h2, "should contain", i)
+		}
+	}
+	corrupt := append([]byte{}, snapshot...)
+	corrupt[100] ^= 1
+	if _, err := ReadHashFrom(bytes.NewReader(corrupt), GobValueCodec[string]()); err != ErrSnapshotChecksum {
+		t.Error("should detect corruption, got", err)
+	}
+	if _, err := ReadHashFrom(bytes.NewReader(snapshot[:len(snapshot)-1]), GobValueCodec[string]()); err != io.ErrUnexpectedEOF {
+		t.Error("should detect truncation, got", err)
+	}
+	corrupt = append([]byte{}, snapshot...)
+	corrupt[len(snapshot_magic)]++
+	if _, err := ReadHashFrom(bytes.NewReader(corrupt), GobValueCodec[string]()); err != ErrSnapshotFormat {
+		t.Error("should reject unknown versions, got", err)
+	}
+	if _, err := NewHash().WriteTo(buf); err != ErrNoValueCodec {
+		t.Error("should not write without a ValueCodec, got", err)
+	}
+	fmt.Println("...Done TestHashSnapshot")
+}
+
+func TestHashSnapshotConcurrency(t *testing.T) {
+	h := NewHashWithOptions(HashOptions{ValueCodec: GobValueCodec[int]()})
+	for i := 0; i < 1000; i++ {
+		v := i
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&v))
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1000; i < 2000; i++ {
+			v := i
+			h.Put(MakeKey(uint64(i)), unsafe.Pointer(&v))
+			h.Delete(MakeKey(uint64(i - 1000 + 500)))
+		}
+	}()
+	buf := new(bytes.Buffer)
+	if _, err := h.WriteTo(buf); err != nil {
+		t.Error(h, "should write a snapshot, got", err)
+	}
+	wg.Wait()
+	h2, err := ReadHashFrom(buf, GobValueCodec[int]())
+	if err != nil {
+		t.Fatal("should read the snapshot, got", err)
+	}
+	for i := 0; i < 500; i++ {
+		if v, ok := h2.Get(MakeKey(uint64(i))); !ok || *(*int)(v) != i {
+			t.Error(h2, "should contain the unchanged entry", i)
+		}
+	}
+	h2.Each(func(k Key, v unsafe.Pointer) bool {
+		if MakeKey(uint64(*(*int)(v))) != k {
+			t.Error(h2, "should only contain consistent entries, but had", k, *(*int)(v))
+		}
+		return false
+	})
+	fmt.Println("...Done TestHashSnapshotConcurrency")
+}
@@ -0,0 +1,91 @@
+package gotomic
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+const (
+	stat_grows = iota
+	stat_shrinks
+	stat_put_retries
+	stat_put_if_missing_retries
+	stat_add_before_retries
+	stat_counters
+)
+
+// search_histogram_size is the number of buckets in HashStats.SearchSteps.
+const search_histogram_size = 16
+
+// HashStats is a snapshot of the state of a Hash, and of what it has
+// done. The counters are only kept if HashOptions.Stats was set, and are
+// 0 otherwise.
+type HashStats struct {
+	Size     int
+	Exponent uint32
+	// Sentinels is the number of bucket sentinel elements in the list.
+	Sentinels int
+	// Grows and Shrinks count the times the bucket table doubled and halved.
+	Grows   int64
+	Shrinks int64
+	// PutRetries counts the times a Put or PutHC had to start over because another goroutine changed the list or the value first.
+	PutRetries int64
+	// PutIfMissingRetries counts the same for PutIfMissing.
+	PutIfMissingRetries int64
+	// AddBeforeRetries counts the times any operation failed to link a new element, entry or sentinel, into the list.
+	AddBeforeRetries int64
+	// SearchSteps is a histogram of the number of elements each search
+	// for an entry, by Get, Put or any other operation, walked past from
+	// its bucket sentinel. SearchSteps[0] counts searches of 1 element,
+	// and SearchSteps[i] searches of 2^(i-1)+1 to 2^i elements. The last
+	// one also counts all longer searches.
+	SearchSteps [search_histogram_size]int64
+}
+
+type hashStats struct {
+	counters    [stat_counters]int64
+	searchSteps [search_histogram_size]int64
+}
+
+// inc increments the counter c, if self is not nil.
+func (self *hashStats) inc(c int) {
+	if self != nil {
+		atomic.AddInt64(&self.counters[c], 1)
+	}
+}
+
+func (self *hashStats) searched(steps int) {
+	i := bits.Len(uint(steps - 1))
+	if i >= search_histogram_size {
+		i = search_histogram_size - 1
+	}
+	atomic.AddInt64(&self.searchSteps[i], 1)
+}
+
+// Stats returns a snapshot of the state of the Hash. It is not atomic,
+// so the numbers can be slightly inconsistent with each other while the
+// Hash is being changed.
+func (self *Hash) Stats() (rval HashStats) {
+	rval.Size = self.Size()
+	rval.Exponent = atomic.LoadUint32(&self.exponent)
+	rval.Sentinels = int(atomic.LoadInt64(&self.sentinels))
+	if self.stats != nil {
+		rval.Grows = atomic.LoadInt64(&self.stats.counters[stat_grows])
+		rval.Shrinks = atomic.LoadInt64(&self.stats.counters[stat_shrinks])
+		rval.PutRetries = atomic.LoadInt64(&self.stats.counters[stat_put_retries])
+		rval.PutIfMissingRetries = atomic.LoadInt64(&self.stats.counters[stat_put_if_missing_retries])
+		rval.AddBeforeRetries = atomic.LoadInt64(&self.stats.counters[stat_add_before_retries])
+		for i := range rval.SearchSteps {
+			rval.SearchSteps[i] = atomic.LoadInt64(&self.stats.searchSteps[i])
+		}
+	}
+	return
+}
+
+// StatsVar returns a func taking a snapshot of the Stats of the Hash,
+// to publish with expvar.Publish(name, expvar.Func(h.StatsVar())).
+func (self *Hash) StatsVar() func() any {
+	return func() any {
+		return self.Stats()
+	}
+}
@@ -0,0 +1,75 @@
+package gotomic
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+func TestHashStats(t *testing.T) {
+	h := NewHashWithOptions(HashOptions{Stats: true})
+	v := "v"
+	for i := 0; i < 100; i++ {
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&v))
+	}
+	for i := 0; i < 100; i++ {
+		h.Get(MakeKey(uint64(i)))
+	}
+	s := h.Stats()
+	if s.Size != 100 || s.Exponent != h.exponent {
+		t.Error(h, "should report size 100 and exponent", h.exponent, "but reported", s)
+	}
+	if s.Grows != int64(s.Exponent) {
+		t.Error(h, "should report", s.Exponent, "grows but reported", s.Grows)
+	}
+	if s.Sentinels == 0 || s.Sentinels > 1<<s.Exponent {
+		t.Error(h, "should report between 1 and", 1<<s.Exponent, "sentinels but reported", s.Sentinels)
+	}
+	var searches int64
+	for _, n := range s.SearchSteps {
+		searches += n
+	}
+	if searches != 200 {
+		t.Error(h, "should report 200 searches, by 100 Puts and 100 Gets, but reported", searches)
+	}
+	for i := 0; i < 100; i++ {
+		h.Delete(MakeKey(uint64(i)))
+	}
+	if s = h.Stats(); s.Shrinks == 0 || s.Size != 0 {
+		t.Error(h, "should report shrinking to size 0, but reported", s)
+	}
+	sentinels := 0
+	h.eachElementBetween(0, 0, func(n *element) bool {
+		if !n.entry.real() && !isDeleted(n.Pointer) {
+			sentinels++
+		}
+		return false
+	})
+	if s.Sentinels != sentinels {
+		t.Error(h, "should report the", sentinels, "sentinels in the list but reported", s.Sentinels)
+	}
+	var decoded HashStats
+	published, err := json.Marshal(h.StatsVar()())
+	if err == nil {
+		err = json.Unmarshal(published, &decoded)
+	}
+	if err != nil || decoded != s {
+		t.Error(h, "should publish", s, "but published", decoded, err)
+	}
+	if s = NewHash().Stats(); s.Grows != 0 || s.Sentinels != 0 {
+		t.Error("a new Hash without stats should report no grows and no sentinels, but reported", s)
+	}
+	fmt.Println("...Done TestHashStats")
+}
+
+func TestHashStatsOffAllocs(t *testing.T) {
+	h := NewHash()
+	k := MakeKey(1)
+	h.Put(k, nil)
+	// Overwriting allocates only the new entry and its element.
+	if n := testing.AllocsPerRun(100, func() { h.Put(k, nil) }); n > 2 {
+		t.Error(h, "should allocate twice per Put without stats, but allocated", n, "times")
+	}
+	fmt.Println("...Done TestHashStatsOffAllocs")
+}
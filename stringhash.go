@@ -0,0 +1,136 @@
+package gotomic
+
+import (
+	"hash/maphash"
+	"unsafe"
+)
+
+type StringHashIterator func(k string, v unsafe.Pointer) bool
+
+// StringHash is a Hash with keys of any length, given either as string
+// or []byte. It uses the same split-ordered list as Hash, but keeps each
+// key in a box together with its value, and compares the keys in the
+// boxes instead of a fixed size Key.
+//
+// Hash codes are calculated with hash/maphash, seeded per StringHash.
+type StringHash struct {
+	hash *Hash
+	seed maphash.Seed
+}
+
+// typedValue is the box a value is kept in together with its key. The
+// key comes first, so that a pointer to the box is also a *K.
+type typedValue[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// boxedKeyEqual compares the keys in the boxes cmp and v, for
+// keyEquality.boxed. cmp may also be a plain *K.
+func boxedKeyEqual[K comparable](cmp, v unsafe.Pointer) bool {
+	return *(*K)(cmp) == *(*K)(v)
+}
+
+// lookupKey returns the *K of ld, for the key of a lookup in a Hash
+// using boxedKeyEqual. It is only allocated the first time.
+func lookupKey[K comparable](ld *LocalData) *K {
+	p, ok := ld.key.(*K)
+	if !ok {
+		p = new(K)
+		ld.key = p
+	}
+	return p
+}
+
+func NewStringHash() *StringHash {
+	rval := &StringHash{hash: NewHash(), seed: maphash.MakeSeed()}
+	rval.hash.keys.boxed = boxedKeyEqual[string]
+	return rval
+}
+
+func (self *StringHash) newEntry(hashCode uint32, k string, v unsafe.Pointer) *entry {
+	return &entry{hashCode: uint64(hashCode), hashKey: reverse64(uint64(hashCode)) | 1, value: unsafe.Pointer(&typedValue[string, unsafe.Pointer]{key: k, value: v})}
+}
+
+func (self *StringHash) unbox(p unsafe.Pointer, ok bool) (unsafe.Pointer, bool) {
+	if ok {
+		return (*typedValue[string, unsafe.Pointer])(p).value, true
+	}
+	return nil, false
+}
+
+// HashCode returns the hash code the StringHash uses for k, for use with GetHC.
+func (self *StringHash) HashCode(k string) uint32 {
+	h := maphash.String(self.seed, k)
+	return uint32(h ^ (h >> 32))
+}
+
+// BytesHashCode returns the hash code the StringHash uses for k, for use with GetBytesHC.
+func (self *StringHash) BytesHashCode(k []byte) uint32 {
+	h := maphash.Bytes(self.seed, k)
+	return uint32(h ^ (h >> 32))
+}
+
+func (self *StringHash) Size() int {
+	return self.hash.Size()
+}
+
+// Each will run i on each key and value, and return true if the iteration was interrupted.
+func (self *StringHash) Each(i StringHashIterator) bool {
+	return self.hash.eachEntry(func(e *entry, v unsafe.Pointer) bool {
+		t := (*typedValue[string, unsafe.Pointer])(v)
+		return i(t.key, t.value)
+	})
+}
+
+// ToMap returns a map[string]unsafe.Pointer that is logically identical to the StringHash.
+func (self *StringHash) ToMap() map[string]unsafe.Pointer {
+	rval := make(map[string]unsafe.Pointer)
+	self.Each(func(k string, v unsafe.Pointer) bool {
+		rval[k] = v
+		return false
+	})
+	return rval
+}
+
+// GetHC returns the value at k and whether it was present, using hashCode and ld to avoid allocating.
+func (self *StringHash) GetHC(hashCode uint32, k string, ld *LocalData) (unsafe.Pointer, bool) {
+	key := lookupKey[string](ld)
+	*key = k
+	ld.te.Set(uint64(hashCode), Key{})
+	ld.te.value = unsafe.Pointer(key)
+	rval, ok := self.hash.getLocal(ld)
+	*key = ""
+	return self.unbox(rval, ok)
+}
+
+// GetBytesHC returns the value at k and whether it was present, using hashCode and ld to avoid allocating.
+// k is not converted to a string, and not retained after GetBytesHC returns.
+func (self *StringHash) GetBytesHC(hashCode uint32, k []byte, ld *LocalData) (unsafe.Pointer, bool) {
+	return self.GetHC(hashCode, unsafe.String(unsafe.SliceData(k), len(k)), ld)
+}
+
+// Get returns the value at k and whether it was present in the StringHash.
+func (self *StringHash) Get(k string) (unsafe.Pointer, bool) {
+	return self.GetHC(self.HashCode(k), k, InitLocalData())
+}
+
+// GetBytes returns the value at k and whether it was present in the StringHash.
+func (self *StringHash) GetBytes(k []byte) (unsafe.Pointer, bool) {
+	return self.GetBytesHC(self.BytesHashCode(k), k, InitLocalData())
+}
+
+// Put k and v in the StringHash and return the overwritten value and whether any value was overwritten.
+func (self *StringHash) Put(k string, v unsafe.Pointer) (unsafe.Pointer, bool) {
+	return self.unbox(self.hash.put(self.newEntry(self.HashCode(k), k, v)))
+}
+
+// PutIfMissing will insert v under k if k was missing from the StringHash, and return whether it inserted anything.
+func (self *StringHash) PutIfMissing(k string, v unsafe.Pointer) bool {
+	return self.hash.putIfMissing(self.newEntry(self.HashCode(k), k, v))
+}
+
+// Delete will remove k from the StringHash and return the removed value and whether any value was removed.
+func (self *StringHash) Delete(k string) (unsafe.Pointer, bool) {
+	return self.unbox(self.hash.delete(self.newEntry(self.HashCode(k), k, nil)))
+}
@@ -0,0 +1,65 @@
+package gotomic
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestStringHash(t *testing.T) {
+	h := NewStringHash()
+	prefix := strings.Repeat("a long shared key prefix ", 4)
+	n := 1000
+	values := make([]int, n)
+	for i := 0; i < n; i++ {
+		values[i] = i
+		if _, ok := h.Put(fmt.Sprint(prefix, i), unsafe.Pointer(&values[i])); ok {
+			t.Error(h, "should not contain", i)
+		}
+	}
+	if h.Size() != n {
+		t.Error(h, "should have size", n, "but had", h.Size())
+	}
+	for i := 0; i < n; i++ {
+		if v, ok := h.Get(fmt.Sprint(prefix, i)); !ok || *(*int)(v) != i {
+			t.Error(h, "should contain", i)
+		}
+		if v, ok := h.GetBytes([]byte(fmt.Sprint(prefix, i))); !ok || *(*int)(v) != i {
+			t.Error(h, "should contain", i, "as bytes")
+		}
+	}
+	if _, ok := h.Get(prefix); ok {
+		t.Error(h, "should not contain the bare prefix")
+	}
+	if h.PutIfMissing(fmt.Sprint(prefix, 1), nil) {
+		t.Error(h, "should contain 1")
+	}
+	if v, ok := h.Delete(fmt.Sprint(prefix, 1)); !ok || *(*int)(v) != 1 {
+		t.Error(h, "should have contained 1")
+	}
+	if m := h.ToMap(); len(m) != n-1 {
+		t.Error(h, "should have", n-1, "entries but had", len(m))
+	}
+	h.Put("", nil)
+	if _, ok := h.GetBytes(nil); !ok {
+		t.Error(h, "should contain the empty key")
+	}
+	fmt.Println("...Done TestStringHash")
+}
+
+func TestStringHashGetBytesHCAllocs(t *testing.T) {
+	h := NewStringHash()
+	h.Put("some key that is longer than sixteen bytes", nil)
+	k := []byte("some key that is longer than sixteen bytes")
+	ld := InitLocalData()
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, ok := h.GetBytesHC(h.BytesHashCode(k), k, ld); !ok {
+			t.Error(h, "should contain", string(k))
+		}
+	})
+	if allocs != 0 {
+		t.Error("GetBytesHC should not allocate, but allocated", allocs)
+	}
+	fmt.Println("...Done TestStringHashGetBytesHCAllocs")
+}
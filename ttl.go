@@ -0,0 +1,156 @@
+package gotomic
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// ExpireFunc is called with the key and value of each entry a sweeper
+// removes because it expired.
+type ExpireFunc func(k Key, v unsafe.Pointer)
+
+// ttlStamp is the expiry time of one value of an entry. It only applies
+// while the entry contains that value, so that replacing the value
+// never has to update the value and the expiry time together.
+type ttlStamp struct {
+	value   unsafe.Pointer
+	expires int64
+}
+
+// ttlElement is an element of a Hash with HashOptions.TTL, with the
+// *ttlStamp of the value of its entry, or nil.
+type ttlElement struct {
+	element element
+	expiry  unsafe.Pointer
+}
+
+func newTTLElement() *element {
+	return &(&ttlElement{}).element
+}
+
+// expiry returns the expiry of n, a real element of a Hash with
+// HashOptions.TTL.
+func expiry(n *element) *unsafe.Pointer {
+	return &(*ttlElement)(unsafe.Pointer(n)).expiry
+}
+
+type sweeper struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// expired returns whether v, the value of the real element n, has
+// expired.
+func (self *Hash) expired(n *element, v unsafe.Pointer) bool {
+	if !self.ttl {
+		return false
+	}
+	p := atomic.LoadPointer(expiry(n))
+	if p == nil {
+		return false
+	}
+	stamp := (*ttlStamp)(p)
+	return stamp.value == v && time.Now().UnixNano() >= stamp.expires
+}
+
+// expire removes the entry of element if v, its value, has expired, and
+// returns whether it had.
+func (self *Hash) expire(element *element, v unsafe.Pointer) bool {
+	if !self.expired(element, v) {
+		return false
+	}
+	if atomic.CompareAndSwapPointer(&element.entry.value, v, deletedValue) {
+		e := &element.entry
+		self.remove(&entry{hashCode: e.hashCode, hashKey: e.hashKey, key: e.key}, element)
+	}
+	return true
+}
+
+// PutWithTTL will put k and v in the Hash like Put, but make v expire
+// after ttl. It panics unless the Hash was created with
+// HashOptions.TTL.
+//
+// An expired value reads as missing, and is removed when an operation
+// on k finds it or a sweeper reaches it. Until then it still counts
+// towards Size. Putting k again replaces the expiry time along with the
+// value.
+func (self *Hash) PutWithTTL(k Key, v unsafe.Pointer, ttl time.Duration) (rval unsafe.Pointer, ok bool) {
+	if !self.ttl {
+		panic("gotomic: PutWithTTL used with a Hash without HashOptions.TTL")
+	}
+	newEntry := newRealEntryWithHashCode(k, v, self.hashCode(k))
+	return self.putStamped(newEntry, unsafe.Pointer(&ttlStamp{value: v, expires: time.Now().Add(ttl).UnixNano()}))
+}
+
+// Sweep removes all expired entries from the Hash, calls onExpire (if
+// not nil) for each of them, and returns how many it removed.
+func (self *Hash) Sweep(onExpire ExpireFunc) int {
+	return self.sweepBetween(0, 0, onExpire)
+}
+
+func (self *Hash) sweepBetween(start, end uint64, onExpire ExpireFunc) (rval int) {
+	self.eachElementBetween(start, end, func(n *element) bool {
+		e := &n.entry
+		if !e.real() {
+			return false
+		}
+		// Like expire, but only reporting the entries this sweep removed.
+		if v := e.val(); v != deletedValue && self.expired(n, v) && atomic.CompareAndSwapPointer(&e.value, v, deletedValue) {
+			self.remove(&entry{hashCode: e.hashCode, hashKey: e.hashKey, key: e.key}, n)
+			rval++
+			if onExpire != nil {
+				onExpire(e.key, v)
+			}
+		}
+		return false
+	})
+	return
+}
+
+// StartSweeper starts a goroutine that removes the expired entries from
+// the Hash, and calls onExpire (if not nil) for each of them.
+//
+// The sweeper divides the Hash into the given number of Partitions,
+// and sweeps the next one of them every interval, so that each entry is
+// looked at once every partitions * interval.
+//
+// It returns false, and starts nothing, if a sweeper is already
+// running. Close stops the sweeper. It panics unless interval and
+// partitions are positive.
+func (self *Hash) StartSweeper(interval time.Duration, partitions int, onExpire ExpireFunc) bool {
+	if interval <= 0 || partitions <= 0 {
+		panic("gotomic: StartSweeper needs a positive interval and number of partitions")
+	}
+	s := &sweeper{stop: make(chan struct{}), done: make(chan struct{})}
+	if !atomic.CompareAndSwapPointer(&self.sweeper, nil, unsafe.Pointer(s)) {
+		return false
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for next := 0; ; next++ {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				ps := self.Partitions(partitions)
+				p := ps[next%len(ps)]
+				self.sweepBetween(p.start, p.end, onExpire)
+			}
+		}
+	}()
+	return true
+}
+
+// Close stops the sweeper started by StartSweeper, if any, and waits
+// for it to finish. It always returns nil.
+func (self *Hash) Close() error {
+	if p := atomic.SwapPointer(&self.sweeper, nil); p != nil {
+		s := (*sweeper)(p)
+		close(s.stop)
+		<-s.done
+	}
+	return nil
+}
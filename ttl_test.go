@@ -0,0 +1,168 @@
+package gotomic
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestHashPutWithTTL(t *testing.T) {
+	h := NewHashWithOptions(HashOptions{TTL: true})
+	v1, v2 := "v1", "v2"
+	h.PutWithTTL(MakeKey(1), unsafe.Pointer(&v1), time.Hour)
+	h.PutWithTTL(MakeKey(2), unsafe.Pointer(&v1), time.Millisecond)
+	h.Put(MakeKey(3), unsafe.Pointer(&v1))
+	time.Sleep(2 * time.Millisecond)
+	if v, ok := h.Get(MakeKey(1)); !ok || v != unsafe.Pointer(&v1) {
+		t.Error(h, "should contain 1 => v1")
+	}
+	if _, ok := h.Get(MakeKey(2)); ok {
+		t.Error(h, "should not contain expired 2")
+	}
+	if m := h.ToMap(); len(m) != 2 {
+		t.Error(h, "should map 1 and 3, but mapped", m)
+	}
+	if !h.PutIfMissing(MakeKey(2), unsafe.Pointer(&v2)) {
+		t.Error(h, "should put 2 in place of the expired value")
+	}
+	if v, ok := h.Get(MakeKey(2)); !ok || v != unsafe.Pointer(&v2) {
+		t.Error(h, "should contain 2 => v2 without expiry")
+	}
+	h.PutWithTTL(MakeKey(3), unsafe.Pointer(&v2), time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if _, ok := h.Get(MakeKey(3)); ok {
+		t.Error(h, "should not contain expired 3")
+	}
+	if old, ok := h.Put(MakeKey(3), unsafe.Pointer(&v2)); ok {
+		t.Error(h, "should not return the expired value", old)
+	}
+	if v, ok := h.Get(MakeKey(3)); !ok || v != unsafe.Pointer(&v2) {
+		t.Error(h, "should contain 3 => v2 after putting it without a ttl")
+	}
+	if h.Size() != 3 {
+		t.Error(h, "should have size 3 but had", h.Size())
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("PutWithTTL should panic without HashOptions.TTL")
+			}
+		}()
+		NewHash().PutWithTTL(MakeKey(1), unsafe.Pointer(&v1), time.Hour)
+	}()
+	fmt.Println("...Done TestHashPutWithTTL")
+}
+
+type ttlString string
+
+func (self ttlString) Equals(t Thing) bool {
+	return t == Thing(string(self))
+}
+
+func TestHashExpiredValues(t *testing.T) {
+	h := NewHashWithOptions(HashOptions{TTL: true})
+	var v Thing = "v"
+	var v2 Thing = "v2"
+	for i := 0; i < 5; i++ {
+		h.PutWithTTL(MakeKey(uint64(i)), unsafe.Pointer(&v), time.Millisecond)
+	}
+	h.Put(MakeKey(5), unsafe.Pointer(&v))
+	time.Sleep(2 * time.Millisecond)
+	if keys, _, _ := h.Scan(0, 100); len(keys) != 1 || keys[0] != MakeKey(5) {
+		t.Error(h, "should only scan 5, but scanned", keys)
+	}
+	if h.CompareAndSwap(MakeKey(0), unsafe.Pointer(&v), unsafe.Pointer(&v2)) {
+		t.Error(h, "should not swap the expired value of 0")
+	}
+	if h.CompareAndDelete(MakeKey(1), unsafe.Pointer(&v)) {
+		t.Error(h, "should not delete the expired value of 1")
+	}
+	if h.PutIfPresent(MakeKey(2), unsafe.Pointer(&v2), ttlString("v")) {
+		t.Error(h, "should not replace the expired value of 2")
+	}
+	if h.Size() != 3 {
+		t.Error(h, "should have removed the expired 0, 1 and 2, but had size", h.Size())
+	}
+	for i := 0; i < 3; i++ {
+		if _, ok := h.Get(MakeKey(uint64(i))); ok {
+			t.Error(h, "should not contain", i)
+		}
+	}
+	if !h.PutIfPresent(MakeKey(5), unsafe.Pointer(&v2), ttlString("v")) {
+		t.Error(h, "should replace the value of 5")
+	}
+	fmt.Println("...Done TestHashExpiredValues")
+}
+
+func TestHashSweep(t *testing.T) {
+	h := NewHashWithOptions(HashOptions{TTL: true})
+	v := "v"
+	for i := 0; i < 100; i++ {
+		ttl := time.Hour
+		if i%2 == 0 {
+			ttl = time.Millisecond
+		}
+		h.PutWithTTL(MakeKey(uint64(i)), unsafe.Pointer(&v), ttl)
+	}
+	time.Sleep(2 * time.Millisecond)
+	expired := make(map[Key]bool)
+	if n := h.Sweep(func(k Key, v unsafe.Pointer) {
+		expired[k] = true
+	}); n != 50 || len(expired) != 50 {
+		t.Error(h, "should sweep 50 entries but swept", n, len(expired))
+	}
+	for i := 0; i < 100; i += 2 {
+		if !expired[MakeKey(uint64(i))] {
+			t.Error(h, "should have swept", i)
+		}
+	}
+	if h.Size() != 50 {
+		t.Error(h, "should have size 50 but had", h.Size())
+	}
+	fmt.Println("...Done TestHashSweep")
+}
+
+func TestHashSweeper(t *testing.T) {
+	h := NewHashWithOptions(HashOptions{TTL: true})
+	v := "v"
+	for i := 0; i < 100; i++ {
+		h.PutWithTTL(MakeKey(uint64(i)), unsafe.Pointer(&v), time.Millisecond)
+	}
+	var swept int32
+	if !h.StartSweeper(time.Millisecond, 4, func(k Key, v unsafe.Pointer) {
+		atomic.AddInt32(&swept, 1)
+	}) {
+		t.Error(h, "should start a sweeper")
+	}
+	if h.StartSweeper(time.Millisecond, 4, nil) {
+		t.Error(h, "should not start a second sweeper")
+	}
+	for deadline := time.Now().Add(time.Second); h.Size() > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	h.Close()
+	if h.Size() != 0 || atomic.LoadInt32(&swept) != 100 {
+		t.Error(h, "should have swept all 100 entries, but swept", swept)
+	}
+	if !h.StartSweeper(time.Millisecond, 4, nil) {
+		t.Error(h, "should start a sweeper after Close")
+	}
+	h.Close()
+	for _, bad := range [][2]int{{0, 4}, {-1, 4}, {1, 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error(h, "should panic starting a sweeper with interval", bad[0], "and", bad[1], "partitions")
+				}
+			}()
+			h.StartSweeper(time.Duration(bad[0])*time.Millisecond, bad[1], nil)
+		}()
+	}
+	if !h.StartSweeper(time.Millisecond, 4, nil) {
+		t.Error(h, "should start a sweeper after failing to start one")
+	}
+	h.Close()
+	fmt.Println("...Done TestHashSweeper")
+}
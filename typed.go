@@ -0,0 +1,105 @@
+//go:build go1.24
+
+package gotomic
+
+import (
+	"hash/maphash"
+	"unsafe"
+)
+
+type TypedHashIterator[K comparable, V any] func(k K, v V) bool
+
+// TypedHash is a type safe wrapper around Hash, for callers that don't
+// want to build a Key and round trip their values through
+// unsafe.Pointer themselves.
+//
+// Keys can be of any comparable type. They are hashed with
+// hash/maphash, seeded per TypedHash, and kept in a box together with
+// their values, where they are compared like StringHash compares its
+// keys. Hashing any comparable type needs maphash.Comparable, so
+// TypedHash is only built with Go 1.24 or later.
+type TypedHash[K comparable, V any] struct {
+	hash *Hash
+	seed maphash.Seed
+}
+
+func NewTypedHash[K comparable, V any]() *TypedHash[K, V] {
+	rval := &TypedHash[K, V]{hash: NewHash(), seed: maphash.MakeSeed()}
+	rval.hash.keys.boxed = boxedKeyEqual[K]
+	return rval
+}
+
+func (self *TypedHash[K, V]) newEntry(k K, v V) *entry {
+	hashCode := uint64(self.HashCode(k))
+	return &entry{hashCode: hashCode, hashKey: reverse64(hashCode) | 1, value: unsafe.Pointer(&typedValue[K, V]{key: k, value: v})}
+}
+
+func (self *TypedHash[K, V]) unbox(p unsafe.Pointer, ok bool) (rval V, rok bool) {
+	if ok {
+		rval = (*typedValue[K, V])(p).value
+		rok = true
+	}
+	return
+}
+
+func (self *TypedHash[K, V]) Size() int {
+	return self.hash.Size()
+}
+
+// Each will run i on each key and value, and return true if the iteration was interrupted.
+func (self *TypedHash[K, V]) Each(i TypedHashIterator[K, V]) bool {
+	return self.hash.eachEntry(func(e *entry, v unsafe.Pointer) bool {
+		t := (*typedValue[K, V])(v)
+		return i(t.key, t.value)
+	})
+}
+
+// ToMap returns a map[K]V that is logically identical to the TypedHash.
+func (self *TypedHash[K, V]) ToMap() map[K]V {
+	rval := make(map[K]V)
+	self.Each(func(k K, v V) bool {
+		rval[k] = v
+		return false
+	})
+	return rval
+}
+
+// GetHC returns the value at k and whether it was present, using hashCode and ld to avoid allocating.
+// hashCode must be what HashCode returns for k.
+func (self *TypedHash[K, V]) GetHC(hashCode uint32, k K, ld *LocalData) (V, bool) {
+	key := lookupKey[K](ld)
+	*key = k
+	ld.te.Set(uint64(hashCode), Key{})
+	ld.te.value = unsafe.Pointer(key)
+	rval, ok := self.hash.getLocal(ld)
+	var zero K
+	*key = zero
+	return self.unbox(rval, ok)
+}
+
+// HashCode returns the hash code the TypedHash uses for k, for use with GetHC.
+func (self *TypedHash[K, V]) HashCode(k K) uint32 {
+	h := maphash.Comparable(self.seed, k)
+	return uint32(h ^ (h >> 32))
+}
+
+// Get returns the value at k and whether it was present in the TypedHash.
+func (self *TypedHash[K, V]) Get(k K) (V, bool) {
+	return self.GetHC(self.HashCode(k), k, InitLocalData())
+}
+
+// Put k and v in the TypedHash and return the overwritten value and whether any value was overwritten.
+func (self *TypedHash[K, V]) Put(k K, v V) (V, bool) {
+	return self.unbox(self.hash.put(self.newEntry(k, v)))
+}
+
+// PutIfMissing will insert v under k if k was missing from the TypedHash, and return whether it inserted anything.
+func (self *TypedHash[K, V]) PutIfMissing(k K, v V) bool {
+	return self.hash.putIfMissing(self.newEntry(k, v))
+}
+
+// Delete will remove k from the TypedHash and return the removed value and whether any value was removed.
+func (self *TypedHash[K, V]) Delete(k K) (V, bool) {
+	var zero V
+	return self.unbox(self.hash.delete(self.newEntry(k, zero)))
+}
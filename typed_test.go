@@ -0,0 +1,96 @@
+//go:build go1.24
+
+package gotomic
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTypedHash(t *testing.T) {
+	h := NewTypedHash[uint64, string]()
+	cmp := make(map[uint64]string)
+	for i := uint64(0); i < 100; i++ {
+		v := fmt.Sprint("value", i)
+		if old, ok := h.Put(i, v); ok {
+			t.Error(h, "should not contain", i, "but had", old)
+		}
+		cmp[i] = v
+	}
+	if h.Size() != len(cmp) {
+		t.Error(h, "should have size", len(cmp), "but had", h.Size())
+	}
+	if tm := h.ToMap(); !reflect.DeepEqual(tm, cmp) {
+		t.Errorf("%v should be %#v", tm, cmp)
+	}
+	ld := InitLocalData()
+	for k, v := range cmp {
+		if hv, ok := h.GetHC(h.HashCode(k), k, ld); !ok || hv != v {
+			t.Error(h, "should contain", k, "=>", v, "but had", hv)
+		}
+	}
+	if old, ok := h.Put(1, "new"); !ok || old != "value1" {
+		t.Error(h, "should have contained 1 => value1, got", old, ok)
+	}
+	if h.PutIfMissing(1, "newer") {
+		t.Error(h, "should contain 1")
+	}
+	if old, ok := h.Delete(1); !ok || old != "new" {
+		t.Error(h, "should have contained 1 => new, got", old, ok)
+	}
+	if v, ok := h.Get(1); ok {
+		t.Error(h, "should not contain 1, but had", v)
+	}
+	fmt.Println("...Done TestTypedHash")
+}
+
+type typedKey struct {
+	name string
+	id   int
+}
+
+func TestTypedHashComparableKeys(t *testing.T) {
+	h := NewTypedHash[typedKey, int]()
+	for i := 0; i < 100; i++ {
+		h.Put(typedKey{"a key that is longer than sixteen bytes", i}, i)
+		h.Put(typedKey{"another key", i}, -i)
+	}
+	if h.Size() != 200 {
+		t.Error(h, "should have size 200 but had", h.Size())
+	}
+	for i := 0; i < 100; i++ {
+		if v, ok := h.Get(typedKey{"a key that is longer than sixteen bytes", i}); !ok || v != i {
+			t.Error(h, "should contain", i, "but had", v)
+		}
+		if v, ok := h.Get(typedKey{"another key", i}); !ok || v != -i {
+			t.Error(h, "should contain", -i, "but had", v)
+		}
+	}
+	if _, ok := h.Delete(typedKey{"another key", 1}); !ok {
+		t.Error(h, "should have deleted another key 1")
+	}
+	if _, ok := h.Get(typedKey{"another key", 1}); ok {
+		t.Error(h, "should not contain another key 1")
+	}
+	if m := h.ToMap(); len(m) != 199 || m[typedKey{"another key", 2}] != -2 {
+		t.Error(h, "should map 199 keys, but mapped", m)
+	}
+	fmt.Println("...Done TestTypedHashComparableKeys")
+}
+
+func TestTypedHashGetHCAllocs(t *testing.T) {
+	h := NewTypedHash[string, int]()
+	k := "some key that is longer than sixteen bytes"
+	h.Put(k, 1)
+	ld := InitLocalData()
+	allocs := testing.AllocsPerRun(100, func() {
+		if v, ok := h.GetHC(h.HashCode(k), k, ld); !ok || v != 1 {
+			t.Error(h, "should contain", k)
+		}
+	})
+	if allocs != 0 {
+		t.Error("GetHC should not allocate, but allocated", allocs)
+	}
+	fmt.Println("...Done TestTypedHashGetHCAllocs")
+}
@@ -0,0 +1,104 @@
+package gotomic
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+type Uint64HashIterator func(k uint64, v unsafe.Pointer) bool
+
+// Uint64Hash is a Hash specialised for uint64 keys. The keys are stored
+// in the first 8 bytes of the Key of each entry, where MakeKey puts
+// them, and only those 8 bytes are compared. They are hashed with
+// Uint64MixHash directly from the uint64 instead of with crc32 over all
+// 16 bytes.
+type Uint64Hash struct {
+	hash *Hash
+}
+
+func NewUint64Hash() *Uint64Hash {
+	rval := &Uint64Hash{hash: NewHashWithOptions(HashOptions{HashFunc: Uint64MixHash})}
+	rval.hash.keys.uint64s = true
+	return rval
+}
+
+// setUint64 is Set for an entry of a Uint64Hash, writing only the 8
+// bytes of k.
+func (te *entry) setUint64(hc uint64, k uint64) {
+	te.hashCode = hc
+	te.hashKey = reverse64(hc) | 1
+	binary.LittleEndian.PutUint64(te.key[:8], k)
+	te.value = nil
+}
+
+func (self *Uint64Hash) newEntry(k uint64, v unsafe.Pointer) *entry {
+	rval := &entry{}
+	rval.setUint64(uint64(mixUint64(k)), k)
+	rval.value = v
+	return rval
+}
+
+// HashCode returns the hash code the Uint64Hash uses for k, for use with GetHC.
+func (self *Uint64Hash) HashCode(k uint64) uint32 {
+	return mixUint64(k)
+}
+
+func (self *Uint64Hash) Size() int {
+	return self.hash.Size()
+}
+
+// Each will run i on each key and value, and return true if the iteration was interrupted.
+func (self *Uint64Hash) Each(i Uint64HashIterator) bool {
+	return self.hash.Each(func(k Key, v unsafe.Pointer) bool {
+		return i(binary.LittleEndian.Uint64(k[:8]), v)
+	})
+}
+
+// ToMap returns a map[uint64]unsafe.Pointer that is logically identical to the Uint64Hash.
+func (self *Uint64Hash) ToMap() map[uint64]unsafe.Pointer {
+	rval := make(map[uint64]unsafe.Pointer)
+	self.Each(func(k uint64, v unsafe.Pointer) bool {
+		rval[k] = v
+		return false
+	})
+	return rval
+}
+
+// GetHC returns the value at k and whether it was present, using hashCode and ld to avoid allocating.
+func (self *Uint64Hash) GetHC(hashCode uint32, k uint64, ld *LocalData) (unsafe.Pointer, bool) {
+	ld.te.setUint64(uint64(hashCode), k)
+	return self.hash.getLocal(ld)
+}
+
+// Get returns the value at k and whether it was present in the Uint64Hash.
+func (self *Uint64Hash) Get(k uint64) (unsafe.Pointer, bool) {
+	var te entry
+	var hh hashHit
+	var h hit
+	return self.GetHC(mixUint64(k), k, &LocalData{te: &te, hh: &hh, hit: &h})
+}
+
+// Put k and v in the Uint64Hash and return the overwritten value and whether any value was overwritten.
+func (self *Uint64Hash) Put(k uint64, v unsafe.Pointer) (unsafe.Pointer, bool) {
+	return self.hash.put(self.newEntry(k, v))
+}
+
+// PutIfMissing will insert v under k if k was missing from the Uint64Hash, and return whether it inserted anything.
+func (self *Uint64Hash) PutIfMissing(k uint64, v unsafe.Pointer) bool {
+	return self.hash.putIfMissing(self.newEntry(k, v))
+}
+
+// CompareAndSwap will replace the value under k with new if k contains exactly the pointer old, and return whether it replaced anything.
+func (self *Uint64Hash) CompareAndSwap(k uint64, old, new unsafe.Pointer) bool {
+	return self.hash.CompareAndSwap(MakeKey(k), old, new)
+}
+
+// Delete will remove k from the Uint64Hash and return the removed value and whether any value was removed.
+func (self *Uint64Hash) Delete(k uint64) (unsafe.Pointer, bool) {
+	return self.hash.delete(self.newEntry(k, nil))
+}
+
+// CompareAndDelete will remove k from the Uint64Hash if k contains expected, and return whether it removed anything.
+func (self *Uint64Hash) CompareAndDelete(k uint64, expected unsafe.Pointer) bool {
+	return self.hash.CompareAndDelete(MakeKey(k), expected)
+}
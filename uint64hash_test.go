@@ -0,0 +1,106 @@
+package gotomic
+
+import (
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+func TestUint64Hash(t *testing.T) {
+	h := NewUint64Hash()
+	n := 10000
+	values := make([]int, n)
+	for i := 0; i < n; i++ {
+		values[i] = i
+		if _, ok := h.Put(uint64(i), unsafe.Pointer(&values[i])); ok {
+			t.Error(h, "should not contain", i)
+		}
+	}
+	if h.Size() != n {
+		t.Error(h, "should have size", n, "but had", h.Size())
+	}
+	ld := InitLocalData()
+	for i := 0; i < n; i++ {
+		if v, ok := h.Get(uint64(i)); !ok || *(*int)(v) != i {
+			t.Error(h, "should contain", i)
+		}
+		if v, ok := h.GetHC(h.HashCode(uint64(i)), uint64(i), ld); !ok || *(*int)(v) != i {
+			t.Error(h, "should contain", i)
+		}
+	}
+	if h.PutIfMissing(1, nil) {
+		t.Error(h, "should contain 1")
+	}
+	if !h.CompareAndSwap(1, unsafe.Pointer(&values[1]), unsafe.Pointer(&values[2])) {
+		t.Error(h, "should contain 1 => 1")
+	}
+	if !h.CompareAndDelete(1, unsafe.Pointer(&values[2])) {
+		t.Error(h, "should contain 1 => 2")
+	}
+	if v, ok := h.Delete(2); !ok || *(*int)(v) != 2 {
+		t.Error(h, "should have contained 2")
+	}
+	m := h.ToMap()
+	if len(m) != n-2 {
+		t.Error(h, "should have", n-2, "entries but had", len(m))
+	}
+	if *(*int)(m[uint64(n-1)]) != n-1 {
+		t.Error(h, "should map", n-1, "to", n-1)
+	}
+	if MakeKey(0x0102030405060708)[0] != 0x08 {
+		t.Error("MakeKey should be little endian")
+	}
+	fmt.Println("...Done TestUint64Hash")
+}
+
+func TestUint64HashGetAllocs(t *testing.T) {
+	h := NewUint64Hash()
+	h.Put(1, nil)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, ok := h.Get(1); !ok {
+			t.Error(h, "should contain 1")
+		}
+	})
+	if allocs != 0 {
+		t.Error("Get should not allocate, but allocated", allocs)
+	}
+	fmt.Println("...Done TestUint64HashGetAllocs")
+}
+
+func TestUint64HashLocalData(t *testing.T) {
+	h := NewUint64Hash()
+	h.Put(1, nil)
+	ld := InitLocalData()
+	// Leaves the last 8 bytes of the lookup key set.
+	NewHash().GetHC(0, Key{15: 1}, ld)
+	if _, ok := h.GetHC(h.HashCode(1), 1, ld); !ok {
+		t.Error(h, "should contain 1, comparing only the first 8 bytes of the key")
+	}
+	fmt.Println("...Done TestUint64HashLocalData")
+}
+
+func BenchmarkUint64HashGet(b *testing.B) {
+	h := NewUint64Hash()
+	for i := 0; i < 1<<16; i++ {
+		h.Put(uint64(i), nil)
+	}
+	ld := InitLocalData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := uint64(i & (1<<16 - 1))
+		h.GetHC(h.HashCode(k), k, ld)
+	}
+}
+
+func BenchmarkHashGetHC(b *testing.B) {
+	h := NewHash()
+	for i := 0; i < 1<<16; i++ {
+		h.Put(MakeKey(uint64(i)), nil)
+	}
+	ld := InitLocalData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := MakeKey(uint64(i & (1<<16 - 1)))
+		h.GetHC(k.HashCode(), k, ld)
+	}
+}
@@ -0,0 +1,120 @@
+package gotomic
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+)
+
+// OrderError is returned by Verify for two adjacent elements whose hash
+// keys are out of order.
+type OrderError struct {
+	Previous, Next uint64
+}
+
+func (self *OrderError) Error() string {
+	return fmt.Sprintf("gotomic: hash key %0.64b follows %0.64b", self.Next, self.Previous)
+}
+
+// ParityError is returned by Verify for an element whose hash key
+// doesn't match its hash code, or doesn't tell correctly whether it is a
+// real entry (odd) or a sentinel (even).
+type ParityError struct {
+	HashCode, HashKey uint64
+	Real              bool
+}
+
+func (self *ParityError) Error() string {
+	kind := "sentinel"
+	if self.Real {
+		kind = "real entry"
+	}
+	return fmt.Sprintf("gotomic: %v with hash code %v has hash key %0.64b", kind, self.HashCode, self.HashKey)
+}
+
+// BucketError is returned by Verify for an initialised slot in the
+// bucket directory that doesn't point at the sentinel for its index.
+type BucketError struct {
+	Index, SuperIndex, SubIndex uint64
+	// HashKey is the hash key of the element the slot points at.
+	HashKey uint64
+}
+
+func (self *BucketError) Error() string {
+	return fmt.Sprintf("gotomic: bucket %v (%v, %v) points at the element with hash key %0.64b", self.Index, self.SuperIndex, self.SubIndex, self.HashKey)
+}
+
+// SizeError is returned by Verify when the size of the Hash doesn't
+// match the number of entries in it.
+type SizeError struct {
+	Size, Entries int
+}
+
+func (self *SizeError) Error() string {
+	return fmt.Sprintf("gotomic: size is %v but there are %v entries", self.Size, self.Entries)
+}
+
+// Verify checks the integrity of the Hash, and returns all the problems
+// it finds joined with errors.Join, or nil if there are none. The
+// problems are reported as *OrderError, *ParityError, *BucketError and
+// *SizeError, which can be picked out with errors.As.
+//
+// The size is only compared with the number of entries if the Hash
+// doesn't change while Verify runs, so that check fails spuriously if
+// other goroutines use the Hash at the same time. The others hold at
+// all times.
+//
+// Sentinels that no bucket points at are allowed, since shrinking the
+// Hash can leave them behind in the list.
+func (self *Hash) Verify() error {
+	var errs []error
+	size := self.Size()
+	entries := 0
+	var previous *entry
+	self.eachElementBetween(0, 0, func(n *element) bool {
+		e := &n.entry
+		if previous != nil && e.hashKey < previous.hashKey {
+			errs = append(errs, &OrderError{Previous: previous.hashKey, Next: e.hashKey})
+		}
+		previous = e
+		v := e.val()
+		if e.real() {
+			if e.hashKey != reverse64(e.hashCode)|1 {
+				errs = append(errs, &ParityError{HashCode: e.hashCode, HashKey: e.hashKey, Real: true})
+			}
+			if v != deletedValue {
+				entries++
+			}
+		} else if e.hashKey != reverse64(e.hashCode)&^1 || v != nil {
+			errs = append(errs, &ParityError{HashCode: e.hashCode, HashKey: e.hashKey, Real: v != nil})
+		}
+		return false
+	})
+	exponent := atomic.LoadUint32(&self.exponent)
+	for superIndex := uint32(0); superIndex <= exponent; superIndex++ {
+		p := atomic.LoadPointer(&self.buckets[superIndex])
+		if p == nil {
+			continue
+		}
+		subBuckets := *(*[]unsafe.Pointer)(p)
+		for subIndex := range subBuckets {
+			bucket := (*element)(atomic.LoadPointer(&subBuckets[subIndex]))
+			// Deleted sentinels are left behind by shrink until they are replaced.
+			if bucket == nil || isDeleted(atomic.LoadPointer(&bucket.Pointer)) {
+				continue
+			}
+			index := uint64(subIndex)
+			if superIndex > 0 {
+				index += 1 << (superIndex - 1)
+			}
+			if bucket.entry.hashKey != reverse64(index)&^1 {
+				errs = append(errs, &BucketError{Index: index, SuperIndex: uint64(superIndex), SubIndex: uint64(subIndex), HashKey: bucket.entry.hashKey})
+			}
+		}
+	}
+	if size != entries {
+		errs = append(errs, &SizeError{Size: size, Entries: entries})
+	}
+	return errors.Join(errs...)
+}
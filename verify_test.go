@@ -0,0 +1,60 @@
+package gotomic
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+func TestHashVerify(t *testing.T) {
+	h := NewHash()
+	v := "v"
+	for i := 0; i < 1000; i++ {
+		h.Put(MakeKey(uint64(i)), unsafe.Pointer(&v))
+	}
+	for i := 0; i < 900; i++ {
+		h.Delete(MakeKey(uint64(i)))
+	}
+	if err := h.Verify(); err != nil {
+		t.Error(h, "should verify, got", err)
+	}
+	var sizeError *SizeError
+	h.size++
+	if err := h.Verify(); !errors.As(err, &sizeError) || sizeError.Size != 101 || sizeError.Entries != 100 {
+		t.Error("should have a size error, got", err)
+	}
+	h.size--
+	var reals []*element
+	for n := h.getBucketByIndex(0); n != nil; n = n.next() {
+		if n.entry.real() {
+			reals = append(reals, n)
+		}
+	}
+	first, second := reals[0], reals[1]
+	first.entry.hashKey, second.entry.hashKey = second.entry.hashKey, first.entry.hashKey
+	var orderError *OrderError
+	if err := h.Verify(); !errors.As(err, &orderError) {
+		t.Error("should have an order error, got", err)
+	}
+	first.entry.hashKey, second.entry.hashKey = second.entry.hashKey, first.entry.hashKey
+	first.entry.hashKey &^= 1
+	var parityError *ParityError
+	if err := h.Verify(); !errors.As(err, &parityError) || !parityError.Real || parityError.HashCode != first.entry.hashCode {
+		t.Error("should have a parity error, got", err)
+	}
+	first.entry.hashKey |= 1
+	h.getBucketByIndex(1)
+	subBuckets, subIndex := h.getSubBuckets(1)
+	bucket := subBuckets[subIndex]
+	subBuckets[subIndex] = unsafe.Pointer(first)
+	var bucketError *BucketError
+	if err := h.Verify(); !errors.As(err, &bucketError) || bucketError.Index != 1 {
+		t.Error("should have a bucket error, got", err)
+	}
+	subBuckets[subIndex] = bucket
+	if err := h.Verify(); err != nil {
+		t.Error("should verify again, got", err)
+	}
+	fmt.Println("...Done TestHashVerify")
+}